@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+var errNoApprovalStore = errors.New("no approval store: set approval_dir in the client configuration, or $TIDYDATA_APPROVAL_DIR, to a directory shared by requesters and approvers")
+
+// approvalStore returns the shared store of publish requests configured by
+// $TIDYDATA_APPROVAL_DIR or the approval_dir of the client configuration.
+func approvalStore() (*tidydata.ApprovalStore, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	store := cfg.approvalStore()
+	if store == nil {
+		return nil, errNoApprovalStore
+	}
+	return store, nil
+}
+
+// setupApprovalStore gives the global client the shared approval store.
+// Unless required, it does nothing if no store is configured, leaving the
+// client to refuse every publish state change.
+func setupApprovalStore(required bool) error {
+	store, err := approvalStore()
+	if err == errNoApprovalStore && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	client.SetApprovalStore(store)
+	return nil
+}
+
+func runRequestPublish(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) != 2 {
+		return errors.New("need exactly 2 arguments: <dataset> <version>")
+	}
+	state, err := vdl.StateFromString(stateFlag)
+	if err != nil {
+		return fmt.Errorf("couldn't parse state %v: %v", stateFlag, err)
+	}
+	if err := setupAuditLog(); err != nil {
+		return err
+	}
+	if err := setupApprovalStore(true); err != nil {
+		return err
+	}
+	r, err := client.RequestPublish(ctx, args[0], args[1], state, noteFlag)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Stdout, "requested %s %s -> %s as %s; another identity must run: version approve %s\n", r.Dataset, r.Version, r.State, r.ID, r.ID)
+	return nil
+}
+
+func cmdRequestPublish() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runRequestPublish),
+		Name:     "request-publish",
+		Short:    "request a publish state change for approval",
+		Long:     "Records a request to change the publish state of a version, which is only applied once an identity other than the requester approves it with version approve. Requests are kept in the approval store named by $TIDYDATA_APPROVAL_DIR or the approval_dir of the client configuration, which requesters and approvers must share. The request is recorded in the audit log.",
+		ArgsName: "[--state state] [--note note] <dataset> <version>",
+	}
+	cmd.Flags.StringVar(&stateFlag, "state", "published", "Requested publish state.")
+	cmd.Flags.StringVar(&noteFlag, "note", "", "Note for the approver.")
+	return cmd
+}
+
+func runApprove(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) != 1 {
+		return errors.New("need exactly 1 argument: <request-id>")
+	}
+	if err := setupAuditLog(); err != nil {
+		return err
+	}
+	if err := setupApprovalStore(true); err != nil {
+		return err
+	}
+	r, err := client.Approve(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Stdout, "approved %s: %s %s is now %s\n", r.ID, r.Dataset, r.Version, r.State)
+	return nil
+}
+
+func cmdApprove() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runApprove),
+		Name:     "approve",
+		Short:    "approve and apply a publish request",
+		Long:     "Approves a pending publish request and applies its state change, which is recorded in the audit log with the request's ID. The approver must not share a blessing with the requester, and the version must still be in the state it was in when the request was made. The request is locked while it is approved, so concurrent approvers can't apply it twice; if an approver is killed, the <request-id>.json.lock file it leaves in the approval store must be removed by hand.",
+		ArgsName: "<request-id>",
+	}
+	return cmd
+}
+
+func runPending(ctx *context.T, env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return errors.New("need no arguments")
+	}
+	store, err := approvalStore()
+	if err != nil {
+		return err
+	}
+	requests, err := store.List(tidydata.RequestPending)
+	if err != nil {
+		return err
+	}
+	var selected []*tidydata.PublishRequest
+	for _, r := range requests {
+		if datasetFlag == "" || r.Dataset == datasetFlag {
+			selected = append(selected, r)
+		}
+	}
+	if formatFlag == "json" {
+		return writeJSON(env.Stdout, selected)
+	}
+	tw := tabwriter.NewWriter(env.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tREQUESTED\tDATASET\tVERSION\tSTATE\tREQUESTER\tNOTE")
+	for _, r := range selected {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s -> %s\t%s\t%s\n", r.ID, r.Requested.Format(time.RFC3339), r.Dataset, r.Version, r.PriorState, r.State, strings.Join(r.Requester, ","), r.Note)
+	}
+	return tw.Flush()
+}
+
+func cmdPending() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runPending),
+		Name:     "pending",
+		Short:    "list publish requests awaiting approval",
+		Long:     "Lists the publish requests awaiting approval.",
+		ArgsName: "[--dataset dataset] [--format text|json]",
+	}
+	cmd.Flags.StringVar(&datasetFlag, "dataset", "", "Only list requests for this dataset.")
+	cmd.Flags.StringVar(&formatFlag, "format", "text", "Output format: text or json.")
+	return cmd
+}
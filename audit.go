@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runAudit(ctx *context.T, env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return errors.New("need no arguments")
+	}
+	q := tidydata.AuditQuery{Dataset: datasetFlag, Actor: actorFlag}
+	var err error
+	if sinceFlag != "" {
+		if q.Since, err = tidydata.ParseTime(sinceFlag); err != nil {
+			return err
+		}
+	}
+	if untilFlag != "" {
+		if q.Until, err = tidydata.ParseTime(untilFlag); err != nil {
+			return err
+		}
+	}
+	path, err := localPath("audit.jsonl")
+	if err != nil {
+		return err
+	}
+	records, err := (&tidydata.AuditLog{Path: path}).Query(q)
+	if err != nil {
+		return err
+	}
+	if formatFlag == "json" {
+		return writeJSON(env.Stdout, records)
+	}
+	tw := tabwriter.NewWriter(env.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tID\tOPERATION\tARGS\tIDENTITY\tPRIOR\tRESULT")
+	for _, r := range records {
+		result := "ok"
+		if !r.OK() {
+			result = "error: " + r.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Time.Format(time.RFC3339), r.ID, r.Operation, strings.Join(r.Args, " "), strings.Join(r.Identity, ","), priorString(r), result)
+	}
+	return tw.Flush()
+}
+
+// priorString summarizes the prior value of an audit record.
+func priorString(r tidydata.AuditRecord) string {
+	if r.Prior == nil {
+		return r.PriorError
+	}
+	var parts []string
+	if r.Prior.State != "" {
+		parts = append(parts, "state="+r.Prior.State)
+	}
+	if r.Operation == tidydata.OpUpdateDescription {
+		parts = append(parts, fmt.Sprintf("description=%q", r.Prior.Description))
+	}
+	var aliases []string
+	for a := range r.Prior.Aliases {
+		aliases = append(aliases, a)
+	}
+	sort.Strings(aliases)
+	for _, a := range aliases {
+		parts = append(parts, fmt.Sprintf("%s->%s", a, r.Prior.Aliases[a]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func cmdAudit() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner: v23cmd.RunnerFunc(runAudit),
+		Name:   "audit",
+		Short:  "Queries the local audit log of administrative operations",
+		Long: `Lists the administrative operations performed with the version subcommands
+from this machine: when, by which identity, against which endpoint, with which
+arguments, what the changed value was beforehand and whether the operation
+succeeded. The log is append-only and kept in the local state directory.`,
+		ArgsName: "[--dataset dataset] [--actor blessing] [--since time] [--until time] [--format text|json]",
+	}
+	cmd.Flags.StringVar(&datasetFlag, "dataset", "", "Only list operations on this dataset.")
+	cmd.Flags.StringVar(&actorFlag, "actor", "", "Only list operations by identities whose blessings contain this string.")
+	cmd.Flags.StringVar(&sinceFlag, "since", "", "Only list operations at or after this time (RFC 3339 or YYYY-MM-DD).")
+	cmd.Flags.StringVar(&untilFlag, "until", "", "Only list operations before this time (RFC 3339 or YYYY-MM-DD).")
+	cmd.Flags.StringVar(&formatFlag, "format", "text", "Output format: text or json.")
+	return cmd
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+const catalogFile = "catalog.json"
+
+func runCatalogSync(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return errors.New("catalog sync takes no arguments")
+	}
+	state, err := vdl.StateFromString(publishStateStrFlag)
+	if err != nil {
+		return fmt.Errorf("couldn't parse publish state %v: %v", publishStateStrFlag, err)
+	}
+	req := tidydata.CatalogSyncRequest{MinState: state, Log: env.Stderr}
+	if len(datasetsFlag) > 0 {
+		req.Datasets = strings.Split(datasetsFlag, ",")
+	}
+	c, err := client.SyncCatalog(ctx, req)
+	if err != nil {
+		return err
+	}
+	path, err := localPath(catalogFile)
+	if err != nil {
+		return err
+	}
+	if len(req.Datasets) > 0 {
+		old, err := tidydata.LoadCatalog(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if old != nil && old.Address == c.Address {
+			c = old.Merge(c)
+		}
+	}
+	if err := c.Save(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Stdout, "Catalog synced: %d entries written to %s\n", len(c.Entries), path)
+	return nil
+}
+
+func cmdCatalogSync() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner: v23cmd.RunnerFunc(runCatalogSync),
+		Name:   "sync",
+		Short:  "Crawls the server into the local catalog",
+		Long:   "Crawls datasets, versions, tablesets, tables, columns, filters and their descriptions into the local catalog used by the search command. The responses are also cached for use with --offline.",
+	}
+	cmd.Flags.StringVar(&datasetsFlag, "datasets", "", "Datasets to crawl in a comma-separated string. Crawls all datasets by default, replacing the catalog; otherwise only the entries of these datasets are replaced.")
+	cmd.Flags.StringVar(&publishStateStrFlag, "publish_state", "tested", "minimum publish state of crawled versions")
+	return cmd
+}
+
+func cmdCatalog() *cmdline.Command {
+	return &cmdline.Command{
+		Name:  "catalog",
+		Short: "Manages the local metadata catalog",
+		Long:  "Manages the local metadata catalog searched by the search command.",
+		Children: []*cmdline.Command{
+			cmdCatalogSync(),
+		},
+	}
+}
+
+func runSearch(ctx *context.T, env *cmdline.Env, args []string) error {
+	if len(args) == 0 {
+		return errors.New("need at least 1 argument: <terms>")
+	}
+	path, err := localPath(catalogFile)
+	if err != nil {
+		return err
+	}
+	c, err := tidydata.LoadCatalog(path)
+	if os.IsNotExist(err) {
+		return errors.New("no local catalog: run 'catalog sync' first")
+	}
+	if err != nil {
+		return err
+	}
+	matches := c.Search(tidydata.SearchRequest{
+		Terms:   args,
+		Dataset: datasetFlag,
+		Version: versionFlag,
+		Kind:    kindFlag,
+	})
+	if searchLimitFlag > 0 && len(matches) > searchLimitFlag {
+		matches = matches[:searchLimitFlag]
+	}
+	if formatFlag == "json" {
+		return writeJSON(env.Stdout, matches)
+	}
+	if len(matches) == 0 {
+		fmt.Fprintf(env.Stdout, "No matches for %q in catalog synced %s\n", strings.Join(args, " "), c.Synced.Format(time.RFC3339))
+		return nil
+	}
+	tw := tabwriter.NewWriter(env.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tLOCATION\tSCORE\tDESCRIPTION")
+	for _, m := range matches {
+		description := strings.Join(strings.Fields(m.Description), " ")
+		if len(description) > 80 {
+			description = description[:77] + "..."
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.2f\t%s\n", m.Kind, m.Location(), m.Score, description)
+	}
+	return tw.Flush()
+}
+
+func cmdSearch() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runSearch),
+		Name:     "search",
+		Short:    "Searches the local catalog",
+		Long:     "Searches the names and descriptions of datasets, versions, tablesets, tables, columns and filters in the local catalog. Every term must match; terms match word prefixes.",
+		ArgsName: "[--dataset d] [--version v] [--kind k] <terms>",
+	}
+	cmd.Flags.StringVar(&datasetFlag, "dataset", "", "Only return matches in this dataset.")
+	cmd.Flags.StringVar(&versionFlag, "version", "", "Only return matches in this version.")
+	cmd.Flags.StringVar(&kindFlag, "kind", "", "Only return matches of this kind: dataset, version, tableset, table, column or filter.")
+	cmd.Flags.IntVar(&searchLimitFlag, "limit", 20, "Maximum number of matches to return; 0 for all.")
+	cmd.Flags.StringVar(&formatFlag, "format", "text", "Output format: text or json.")
+	return cmd
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+)
+
+// clientConfig is the client configuration, read from the file named by
+// $TIDYDATA_CLIENT_CONFIG or config.json in localDir, such as
+//
+//	{
+//	  "protected_aliases": [
+//	    {"dataset": "*", "alias": "prod", "consumers": ["clinical-analytics"]},
+//	    {"dataset": "ccga", "alias": "paper-2024"}
+//	  ],
+//	  "approval_dir": "/shared/tidydata/approvals",
+//	  "metadata_ttls": {"versions": "15m", "structure": "720h"}
+//	}
+type clientConfig struct {
+	tidydata.AliasPolicy
+	// ApprovalDir is the directory, shared by requesters and approvers, in
+	// which publish requests are kept; see
+	// tidydata.ApprovalStore. $TIDYDATA_APPROVAL_DIR overrides it.
+	ApprovalDir string `json:"approval_dir"`
+	// MetadataTTLs overrides, per kind of metadata, the time for which
+	// cached metadata is considered fresh; see tidydata.MetadataCache.
+	MetadataTTLs map[string]string `json:"metadata_ttls"`
+}
+
+// metadataTTLs parses MetadataTTLs.
+func (cfg *clientConfig) metadataTTLs() (map[string]time.Duration, error) {
+	ttls := map[string]time.Duration{}
+	for kind, s := range cfg.MetadataTTLs {
+		if _, ok := tidydata.DefaultMetadataTTLs[kind]; !ok {
+			return nil, fmt.Errorf("metadata_ttls: unknown kind of metadata %q", kind)
+		}
+		ttl, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("metadata_ttls: %v", err)
+		}
+		ttls[kind] = ttl
+	}
+	return ttls, nil
+}
+
+// approvalStore returns the shared store of publish requests, or nil if
+// none is configured.
+func (cfg *clientConfig) approvalStore() *tidydata.ApprovalStore {
+	dir := os.Getenv("TIDYDATA_APPROVAL_DIR")
+	if dir == "" {
+		dir = cfg.ApprovalDir
+	}
+	if dir == "" {
+		return nil
+	}
+	return &tidydata.ApprovalStore{Dir: dir}
+}
+
+// loadConfig reads the client configuration. A missing file is an empty
+// configuration.
+func loadConfig() (*clientConfig, error) {
+	path := os.Getenv("TIDYDATA_CLIENT_CONFIG")
+	if path == "" {
+		var err error
+		if path, err = localPath("config.json"); err != nil {
+			return nil, err
+		}
+	}
+	cfg := &clientConfig{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cfg, json.Unmarshal(data, cfg)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runDeprecate(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupAuditLog(); err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return errors.New("need exactly 2 arguments: <dataset> <version>")
+	}
+	if sunsetFlag == "" {
+		return errors.New("--sunset is required")
+	}
+	sunset, err := tidydata.ParseTime(sunsetFlag)
+	if err != nil {
+		return err
+	}
+	d := tidydata.Deprecation{Sunset: sunset, Replacement: replacementFlag}
+	if err := client.Deprecate(ctx, args[0], args[1], d); err != nil {
+		return err
+	}
+	fmt.Fprintln(env.Stdout, d.Warning(args[0], args[1], time.Now()))
+	return nil
+}
+
+func cmdDeprecate() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner: v23cmd.RunnerFunc(runDeprecate),
+		Name:   "deprecate",
+		Short:  "deprecate a version",
+		Long: `Marks a version as deprecated, so that tidyset, preprocessed-data and
+describe warn everyone who requests it, and fail with --strict once the
+sunset date has passed. The sunset date is inclusive: the version is
+supported through the end of that day, UTC.
+
+The deprecation is kept as a machine-readable line of the version
+description, replacing any earlier one,
+
+  deprecated: sunset=2025-06-30 replacement=v12
+
+which describe shows along with the rest of the description. The server
+has no separate deprecation field, so the line is lost if the description
+is overwritten without it.`,
+		ArgsName: "--sunset date [--replacement version] <dataset> <version>",
+	}
+	cmd.Flags.StringVar(&sunsetFlag, "sunset", "", "Last date on which the version is supported (YYYY-MM-DD); it may be removed after it.")
+	cmd.Flags.StringVar(&replacementFlag, "replacement", "", "Version to use instead.")
+	return cmd
+}
+
+// warnDeprecated prints a warning if a version, which may be an alias, is
+// deprecated, and fails if --strict is set and its sunset date has passed.
+func warnDeprecated(ctx *context.T, env *cmdline.Env, dataset, version string) error {
+	d, resolved, err := client.CheckDeprecation(ctx, dataset, version)
+	if errors.Is(err, tidydata.ErrNotCached) {
+		fmt.Fprintf(env.Stderr, "warning: offline: couldn't check whether %s %s is deprecated: %v\n", dataset, version, err)
+		return nil
+	}
+	if err != nil || d == nil {
+		return err
+	}
+	now := time.Now()
+	warning := d.Warning(dataset, resolved, now)
+	if strictFlag && d.Expired(now) {
+		return errors.New(warning)
+	}
+	fmt.Fprintf(env.Stderr, "warning: %s\n", warning)
+	return nil
+}
+
+// addStrictFlag adds the --strict flag to a command that warns about
+// deprecated versions.
+func addStrictFlag(cmd *cmdline.Command) {
+	cmd.Flags.BoolVar(&strictFlag, "strict", false, "Fail if the version is deprecated and its sunset date has passed.")
+}
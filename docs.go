@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runDocs(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) != 2 {
+		return errors.New("need exactly 2 arguments: <dataset> <version>")
+	}
+	if docsOutFlag == "" {
+		return errors.New("--out is required")
+	}
+	site, err := client.BuildDocsSite(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if err := tidydata.WriteDocsSite(docsOutFlag, site); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Stdout, "Wrote data dictionary for %s %s to %s\n", args[0], args[1], filepath.Join(docsOutFlag, "index.html"))
+	return nil
+}
+
+func cmdDocs() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runDocs),
+		Name:     "docs",
+		Short:    "Generates a data dictionary site for a dataset version",
+		Long:     "Generates a static HTML and Markdown data dictionary for a dataset version, with a page per tableset, table and filter, column descriptions and rules, row counts, filter query strings and a search index.",
+		ArgsName: "--out dir <dataset> <version>",
+	}
+	cmd.Flags.StringVar(&docsOutFlag, "out", "", "Directory to write the site to.")
+	return cmd
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/x/lib/cmdline"
+)
+
+// printAccessExplanation prints the result of check-access --explain.
+func printAccessExplanation(env *cmdline.Env, exp *tidydata.AccessExplanation) {
+	w := env.Stdout
+	req := exp.Request
+	fmt.Fprintln(w, "Caller blessings:")
+	for _, b := range exp.CallerBlessings {
+		fmt.Fprintf(w, "  %s\n", b)
+	}
+	fmt.Fprintf(w, "Checked identity: %s\n", req.Identity)
+	fmt.Fprintf(w, "Request: %s %s %s", req.Dataset, req.Version, req.Tableset)
+	if len(req.Filters) > 0 {
+		fmt.Fprintf(w, " filters=%s", strings.Join(req.Filters, ","))
+	}
+	fmt.Fprintln(w)
+
+	if len(exp.Objects) > 0 {
+		fmt.Fprintln(w, "\nThe server does not report which ACLs it checks; these are those of the")
+		fmt.Fprintln(w, "objects it is expected to consult, as a guide only.")
+	}
+	for _, obj := range exp.Objects {
+		fmt.Fprintf(w, "\nACLs for %s (%s):\n", obj.Kind, obj.Name)
+		if obj.Error != "" {
+			fmt.Fprintf(w, "  unavailable: %s\n", obj.Error)
+			continue
+		}
+		for _, acl := range obj.ACLs {
+			fmt.Fprintf(w, "  %s: in=[%s]", acl.Tag, strings.Join(acl.In, ", "))
+			if len(acl.NotIn) > 0 {
+				fmt.Fprintf(w, " not_in=[%s]", strings.Join(acl.NotIn, ", "))
+			}
+			if acl.Granted {
+				fmt.Fprintln(w, " (granted)")
+				continue
+			}
+			fmt.Fprintln(w, " (denied)")
+			switch {
+			case len(acl.ExcludedBy) > 0:
+				fmt.Fprintf(w, "    excluded by: %s\n", strings.Join(acl.ExcludedBy, ", "))
+			case acl.Missing != "":
+				fmt.Fprintf(w, "    missing: a blessing matching %s\n", acl.Missing)
+			default:
+				fmt.Fprintln(w, "    missing: the ACL admits no one")
+			}
+		}
+	}
+
+	fmt.Fprintln(w)
+	if exp.Error == "" {
+		fmt.Fprintf(w, "Result: %s has access\n", req.Identity)
+		return
+	}
+	fmt.Fprintf(w, "Result: access denied: %s\n", exp.Error)
+	switch {
+	case exp.FilterListError != "":
+		fmt.Fprintf(w, "Could not list filters: %s\n", exp.FilterListError)
+	case len(exp.AllowedFilters) == 0:
+		fmt.Fprintln(w, "No filter-restricted subset would have been allowed.")
+	default:
+		fmt.Fprintln(w, "Access would have been allowed with any of these additional filters:")
+		for _, f := range exp.AllowedFilters {
+			fmt.Fprintf(w, "  --filters %s\n", strings.Join(append(append([]string{}, req.Filters...), f), ","))
+		}
+	}
+}
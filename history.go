@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+// fetchQuery returns the fetch log query given by the --dataset, --user,
+// --since and --until flags.
+func fetchQuery() (tidydata.FetchQuery, error) {
+	q := tidydata.FetchQuery{Dataset: datasetFlag, Version: versionFlag, User: userFlag}
+	var err error
+	if sinceFlag != "" {
+		if q.Since, err = tidydata.ParseTime(sinceFlag); err != nil {
+			return q, err
+		}
+	}
+	if untilFlag != "" {
+		if q.Until, err = tidydata.ParseTime(untilFlag); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+// queryFetchLog returns the records of the local fetch log selected by the
+// query flags.
+func queryFetchLog() ([]tidydata.FetchRecord, error) {
+	q, err := fetchQuery()
+	if err != nil {
+		return nil, err
+	}
+	path, err := localPath("fetches.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	return (&tidydata.FetchLog{Path: path}).Query(q)
+}
+
+// addFetchQueryFlags adds the flags selecting fetch log records.
+func addFetchQueryFlags(cmd *cmdline.Command) {
+	cmd.Flags.StringVar(&datasetFlag, "dataset", "", "Only include fetches of this dataset.")
+	cmd.Flags.StringVar(&versionFlag, "version", "", "Only include fetches of this version or alias.")
+	cmd.Flags.StringVar(&userFlag, "user", "", "Only include fetches by this local user or by identities whose blessings contain this string.")
+	cmd.Flags.StringVar(&sinceFlag, "since", "", "Only include fetches at or after this time (RFC 3339 or YYYY-MM-DD).")
+	cmd.Flags.StringVar(&untilFlag, "until", "", "Only include fetches before this time (RFC 3339 or YYYY-MM-DD).")
+	cmd.Flags.StringVar(&formatFlag, "format", "text", "Output format: text or json.")
+}
+
+func runHistory(ctx *context.T, env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return errors.New("need no arguments")
+	}
+	records, err := queryFetchLog()
+	if err != nil {
+		return err
+	}
+	if limitFlag > 0 && len(records) > limitFlag {
+		records = records[len(records)-limitFlag:]
+	}
+	if formatFlag == "json" {
+		return writeJSON(env.Stdout, records)
+	}
+	tw := tabwriter.NewWriter(env.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tUSER\tKIND\tDATASET\tREQUESTED\tVERSION\tTABLESET\tSIZE\tDURATION\tCACHE\tRESULT")
+	for _, r := range records {
+		cache, result := "miss", "ok"
+		if r.CacheHit {
+			cache = "hit"
+		}
+		if r.Error != "" {
+			result = "error: " + r.Error
+		}
+		tableset := r.Tableset
+		if len(r.Filters) > 0 {
+			tableset += " [" + strings.Join(r.Filters, ",") + "]"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", r.Time.Format(time.RFC3339), r.User, r.Kind, r.Dataset, r.Requested, r.Version, tableset, r.Size, time.Duration(r.DurationMS)*time.Millisecond, cache, result)
+	}
+	return tw.Flush()
+}
+
+func cmdHistory() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runHistory),
+		Name:     "history",
+		Short:    "Lists the tidysets and preprocessed data fetched from this machine",
+		Long:     "Lists the tidyset and preprocessed-data fetches recorded in the local fetch log: the request, the version it resolved to, the size of the data, how long the fetch took, whether it was served from the local cache and whether it failed. The log is rotated as it grows, so only the most recent fetches are kept.",
+		ArgsName: "[--dataset dataset] [--version version] [--user user] [--since time] [--until time] [--limit n] [--format text|json]",
+	}
+	addFetchQueryFlags(cmd)
+	cmd.Flags.IntVar(&limitFlag, "limit", 0, "Only list the most recent n fetches; 0 lists all.")
+	return cmd
+}
+
+func runUsageReport(ctx *context.T, env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return errors.New("need no arguments")
+	}
+	records, err := queryFetchLog()
+	if err != nil {
+		return err
+	}
+	by := strings.Split(byFlag, ",")
+	report, err := tidydata.UsageReport(records, by)
+	if err != nil {
+		return err
+	}
+	if formatFlag == "json" {
+		return writeJSON(env.Stdout, report)
+	}
+	tw := tabwriter.NewWriter(env.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tFETCHES\tFAILURES\tCACHE HITS\tBYTES\tTIME\tLAST\n", strings.ToUpper(strings.Join(by, "\t")))
+	for _, row := range report {
+		var group []string
+		for _, dim := range by {
+			group = append(group, row.Group[dim])
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\t%s\n", strings.Join(group, "\t"), row.Fetches, row.Failures, row.CacheHits, row.Bytes, time.Duration(row.DurationMS)*time.Millisecond, row.Last.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func cmdUsageReport() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runUsageReport),
+		Name:     "usage-report",
+		Short:    "Summarizes the fetches made from this machine",
+		Long:     "Summarizes the fetches recorded in the local fetch log, grouped by dataset, version, tableset, user or kind, over a time window. The log is rotated as it grows, so only the most recent fetches are counted.",
+		ArgsName: "[--by dimensions] [--dataset dataset] [--version version] [--user user] [--since time] [--until time] [--format text|json]",
+	}
+	addFetchQueryFlags(cmd)
+	cmd.Flags.StringVar(&byFlag, "by", "dataset,version", "Comma-separated dimensions to group by: dataset, version, tableset, user or kind.")
+	return cmd
+}
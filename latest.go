@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runLatest(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("need exactly 1 argument: <dataset>")
+	}
+	state, err := vdl.StateFromString(stateFlag)
+	if err != nil {
+		return fmt.Errorf("couldn't parse state %v: %v", stateFlag, err)
+	}
+	if err := setupVersionHistory(); err != nil {
+		return err
+	}
+	req := tidydata.LatestRequest{
+		Dataset:  args[0],
+		MinState: state,
+		Tableset: withTablesetFlag,
+	}
+	if asOfFlag != "" {
+		if req.AsOf, err = tidydata.ParseTime(asOfFlag); err != nil {
+			return err
+		}
+	}
+	version, err := client.LatestVersion(ctx, req)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(env.Stdout, version)
+	return nil
+}
+
+func cmdLatest() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runLatest),
+		Name:     "latest",
+		Short:    "Prints the latest version of a dataset",
+		Long:     "Prints the last version, in natural version order, of a dataset that is at or above a publish state, is not failed and, optionally, includes a tableset. Exits non-zero if no version qualifies. With --as-of, selects by the publish states versions were observed in at that time by this machine, as recorded in its local version history; the tidy server keeps no history, so versions this machine had not yet observed are not considered.",
+		ArgsName: "[--state state] [--with-tableset tableset] [--as-of time] <dataset>",
+	}
+	cmd.Flags.StringVar(&stateFlag, "state", "published", "Minimum publish state of the version.")
+	cmd.Flags.StringVar(&withTablesetFlag, "with-tableset", "", "Only consider versions that include this tableset.")
+	addAsOfFlag(cmd)
+	addOfflineFlag(cmd)
+	return cmd
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runLineage(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) != 2 {
+		return errors.New("need exactly 2 arguments: <dataset> <version>")
+	}
+	root, err := client.Lineage(ctx, tidydata.LineageRequest{
+		Dataset: args[0],
+		Version: args[1],
+		Depth:   depthFlag,
+	})
+	if err != nil {
+		return err
+	}
+	if lineageFormatFlag == "json" {
+		return writeJSON(env.Stdout, root)
+	}
+	return tidydata.WriteLineage(env.Stdout, root, lineageFormatFlag)
+}
+
+func cmdLineage() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner: v23cmd.RunnerFunc(runLineage),
+		Name:   "lineage",
+		Short:  "Shows the inputs that produced a dataset version",
+		Long: `Shows the inputs that produced a dataset version: the clinical snapshots it
+includes and the upstream dataset versions, pipeline runs and preprocessed
+data in its lineage record, as set by version set-lineage. For versions
+without one, inputs are inferred from description lines such as
+
+  upstream: ccga@v3, pcr@2024_01
+  pipeline run: 8412
+  preprocessed: labs_2024_01
+
+Upstream versions are followed recursively up to --depth levels.
+
+The lineage record is a machine-readable "lineage: {...}" line of the
+version description, so describe shows it alongside the free text and
+editing the description by hand can change or remove it.`,
+		ArgsName: "[--format tree|dot|mermaid|json] [--depth n] <dataset> <version>",
+	}
+	cmd.Flags.StringVar(&lineageFormatFlag, "format", "tree", "Output format: tree, dot (Graphviz), mermaid or json.")
+	cmd.Flags.IntVar(&depthFlag, "depth", 3, "Number of levels of upstream versions to follow.")
+	return cmd
+}
+
+func runSetLineage(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupAuditLog(); err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return errors.New("need exactly 2 arguments: <dataset> <version>")
+	}
+	var record tidydata.LineageRecord
+	if upstreamFlag != "" {
+		for _, ref := range strings.Split(upstreamFlag, ",") {
+			u, err := tidydata.ParseLineageRef(ref)
+			if err != nil {
+				return err
+			}
+			record.Upstream = append(record.Upstream, u)
+		}
+	}
+	if runsFlag != "" {
+		record.Runs = strings.Split(runsFlag, ",")
+	}
+	if preprocessedFlag != "" {
+		record.Preprocessed = strings.Split(preprocessedFlag, ",")
+	}
+	return client.SetLineage(ctx, args[0], args[1], record)
+}
+
+func cmdSetLineage() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner: v23cmd.RunnerFunc(runSetLineage),
+		Name:   "set-lineage",
+		Short:  "record the inputs of a version",
+		Long: `Records the upstream versions, pipeline runs and preprocessed data that
+produced a version, replacing any earlier record, so that lineage shows them
+to every client.
+
+The record is kept as a machine-readable line of the version description,
+
+  lineage: {"upstream":[{"dataset":"ccga","version":"v3"}],"runs":["8412"]}
+
+which describe shows along with the rest of the description. The server
+has no separate lineage field, so the line is lost if the description is
+overwritten without it.`,
+		ArgsName: "[--upstream dataset@version,...] [--runs id,...] [--preprocessed name,...] <dataset> <version>",
+	}
+	cmd.Flags.StringVar(&upstreamFlag, "upstream", "", "Comma-separated upstream versions, each as dataset@version.")
+	cmd.Flags.StringVar(&runsFlag, "runs", "", "Comma-separated ids of the pipeline runs that produced the version.")
+	cmd.Flags.StringVar(&preprocessedFlag, "preprocessed", "", "Comma-separated names of the preprocessed data the version was built from.")
+	return cmd
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+)
+
+// localDir returns the directory in which the client keeps local state such
+// as the metadata catalog, creating it if needed. It is
+// $TIDYDATA_CLIENT_DIR if set, and ~/.grail-tidydata-client otherwise.
+func localDir() (string, error) {
+	dir := os.Getenv("TIDYDATA_CLIENT_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".grail-tidydata-client")
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// localPath returns the path of name within localDir.
+func localPath(name string) (string, error) {
+	dir, err := localDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// setupVersionHistory makes the global client record the versions it lists
+// in the local version history.
+func setupVersionHistory() error {
+	path, err := localPath("versions.json")
+	if err != nil {
+		return err
+	}
+	h, err := tidydata.LoadVersionHistory(path)
+	if err != nil {
+		return err
+	}
+	client.SetVersionHistory(h)
+	return nil
+}
+
+// setupFetchLog makes the global client record the data it fetches in the
+// local fetch log.
+func setupFetchLog() error {
+	path, err := localPath("fetches.jsonl")
+	if err != nil {
+		return err
+	}
+	client.SetFetchLog(&tidydata.FetchLog{Path: path})
+	return nil
+}
+
+// setupAuditLog makes the global client record the administrative
+// operations it performs in the local audit log.
+func setupAuditLog() error {
+	path, err := localPath("audit.jsonl")
+	if err != nil {
+		return err
+	}
+	client.SetAuditLog(&tidydata.AuditLog{Path: path})
+	return nil
+}
+
+// writeJSON writes the indented JSON encoding of v to w.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
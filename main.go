@@ -11,8 +11,8 @@ import (
 	"time"
 
 	_ "github.com/grailbio/v23/factories/grail"
+	"grail.com/cmd/tidydata-client/tidydata"
 	"grail.com/tidy/dataframe"
-	tidy "grail.com/tidy/vanadium/client"
 	vdl "grail.com/tidy/vanadium/vdl/dataset"
 	v23 "v.io/v23"
 	"v.io/v23/context"
@@ -27,9 +27,68 @@ var (
 	outputFlag          string
 	publishStateStrFlag string
 	identityFlag        string
+	explainFlag         bool
+	formatFlag          string
+	releaseNotesURLFlag string
+	notesFormatFlag     string
+	docsOutFlag         string
+	datasetsFlag        string
+	datasetFlag         string
+	versionFlag         string
+	kindFlag            string
+	limitFlag           int
+	searchLimitFlag     int
+	topFlag             int
+	aliasFlag           string
+	execFlag            string
+	webhookFlag         string
+	watchIntervalFlag   time.Duration
+	waitIntervalFlag    time.Duration
+	stateFlag           string
+	timeoutFlag         time.Duration
+	httpFlag            string
+	tlsCertFlag         string
+	tlsKeyFlag          string
+	clientCAFlag        string
+	tokenKeyFlag        string
+	tokenTTLFlag        time.Duration
+	writeTimeoutFlag    time.Duration
+	verifyFlag          bool
+	sha256Flag          string
+	signatureFlag       string
+	publicKeyFlag       string
+	snapshotFlag        string
+	dbFlag              string
+	binsFlag            int
+	keyFlag             string
+	fractionFlag        float64
+	seedFlag            int64
+	sortFlag            string
+	sinceFlag           string
+	untilFlag           string
+	latestPerStateFlag  bool
+	offsetFlag          int
+	withTablesetFlag    string
+	asOfFlag            string
+	depthFlag           int
+	lineageFormatFlag   string
+	upstreamFlag        string
+	runsFlag            string
+	preprocessedFlag    string
+	actorFlag           string
+	forceFlag           bool
+	reasonFlag          string
+	noteFlag            string
+	sunsetFlag          string
+	replacementFlag     string
+	strictFlag          bool
+	userFlag            string
+	byFlag              string
+	offlineFlag         bool
+	progressFlag        string
 	verboseFlag         bool
 	withAliasesFlag     bool
-	client              tidy.Client
+	client              *tidydata.Client
 )
 
 func cmdRoot() *cmdline.Command {
@@ -48,6 +107,22 @@ func cmdRoot() *cmdline.Command {
 			cmdCheckAccess(),
 			cmdPreprocessed(),
 			cmdReleaseNotes(),
+			cmdDocs(),
+			cmdCatalog(),
+			cmdSearch(),
+			cmdWatch(),
+			cmdWait(),
+			cmdServe(),
+			cmdValidate(),
+			cmdVerify(),
+			cmdProfile(),
+			cmdSubset(),
+			cmdLatest(),
+			cmdResolve(),
+			cmdLineage(),
+			cmdAudit(),
+			cmdHistory(),
+			cmdUsageReport(),
 		},
 		Topics: []cmdline.Topic{},
 	}
@@ -70,37 +145,51 @@ func outputPathAndVersion(env *cmdline.Env, path, version string) error {
 }
 
 func runTidyset(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
+	args, err := resolveVersionArgs(ctx, env, args, 3)
+	if err != nil {
+		return err
+	}
 	if err := parseTidyArgs(args); err != nil {
 		return err
 	}
-	var filters []string
+	req := tidydata.TidysetRequest{
+		Dataset:  args[0],
+		Version:  args[1],
+		Tableset: args[2],
+		Output:   outputFlag,
+	}
 	if len(filtersFlag) > 0 {
-		filters = strings.Split(filtersFlag, ",")
+		req.Filters = strings.Split(filtersFlag, ",")
 	}
-	var filtersToMaterialize []string
 	if len(materializeFlag) > 0 {
-		filtersToMaterialize = strings.Split(materializeFlag, ",")
+		req.Materialize = strings.Split(materializeFlag, ",")
 	}
 
 	if args[0] == "client-test" {
 		return loadTestData(env)
 	}
+	if err := warnDeprecated(ctx, env, req.Dataset, req.Version); err != nil {
+		return err
+	}
 
-	path, version, err := client.GetData(args[0], args[1], args[2], filters, filtersToMaterialize)
-	if err != nil {
+	if req.Expected, err = setupVerification(); err != nil {
 		return err
 	}
-	if outputFlag != "" {
-		data, err := ioutil.ReadFile(path)
-		if err != nil {
-			return err
-		}
-		if err = ioutil.WriteFile(outputFlag, data, os.ModePerm); err != nil {
-			return err
-		}
+	if err := setupFetchLog(); err != nil {
+		return err
+	}
+	if err := setupProgress(env); err != nil {
+		return err
 	}
-	return outputPathAndVersion(env, path, version)
+	fetched, err := client.Tidyset(ctx, req)
+	if err != nil {
+		return err
+	}
+	return outputPathAndVersion(env, fetched.Path, fetched.Version)
 }
 
 func cmdReleaseNotes() *cmdline.Command {
@@ -108,44 +197,56 @@ func cmdReleaseNotes() *cmdline.Command {
 		Runner:   v23cmd.RunnerFunc(runReleaseNotes),
 		Name:     "release-notes",
 		Short:    "Returns URL to release notes for given dataset and version",
-		Long:     "Returns URL to release notes for given dataset and version",
-		ArgsName: "[--filters filters] <dataset> <version>",
+		Long:     "Returns URL to release notes for given dataset and version, or generates release notes locally with the generate subcommand.",
+		ArgsName: "[--url-template template] <dataset> <version>",
+		Children: []*cmdline.Command{
+			cmdGenerateReleaseNotes(),
+		},
 	}
+	addReleaseNotesURLFlag(cmd)
 	return cmd
 }
 
 func runReleaseNotes(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
-	_, err := client.GetVersionFor(args[1], args[0])
-	if err != nil {
-		return err
-	}
+	client = tidydata.NewClient(ctx, addressFlag)
 	if len(args) != 2 {
 		return errors.New("need exactly 2 arguments: <dataset> <version>")
 	}
-	fmt.Printf("https://confluence.ti-apps.aws.grail.com/display/TIDY/Release+%s-%s-Tidydata+Software+Release+Notes", args[1], args[0])
+	if _, err := client.ResolveVersion(ctx, args[0], args[1]); err != nil {
+		return err
+	}
+	fmt.Println(tidydata.ReleaseNotesURL(releaseNotesURLFlag, args[0], args[1]))
 	return nil
 }
 
 func runCheckAccess(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
 	if err := parseTidyArgs(args); err != nil {
 		return err
 	}
-	var filters []string
+	blessings, _ := v23.GetPrincipal(ctx).BlessingStore().Default()
+	req := tidydata.AccessRequest{
+		Identity: blessings.String(),
+		Dataset:  args[0],
+		Version:  args[1],
+		Tableset: args[2],
+	}
 	if len(filtersFlag) > 0 {
-		filters = strings.Split(filtersFlag, ",")
+		req.Filters = strings.Split(filtersFlag, ",")
 	}
-	blessings, _ := v23.GetPrincipal(ctx).BlessingStore().Default()
-	identity := blessings.String()
 	if len(identityFlag) > 0 {
-		identity = identityFlag
+		req.Identity = identityFlag
 	}
 
-	if err := client.CheckAccess(identity, args[0], args[1], args[2], filters); err != nil {
+	if explainFlag {
+		exp, err := client.ExplainAccess(ctx, req)
+		printAccessExplanation(env, exp)
 		return err
 	}
-	fmt.Printf("%s has access to %s %s %s\n", identity, args[0], args[1], args[2])
+	if err := client.CheckAccess(ctx, req); err != nil {
+		return err
+	}
+	fmt.Printf("%s has access to %s %s %s\n", req.Identity, args[0], args[1], args[2])
 	return nil
 }
 
@@ -155,10 +256,11 @@ func cmdCheckAccess() *cmdline.Command {
 		Name:     "check-access",
 		Short:    "Checks if a v23 identity has access to a certain request",
 		Long:     "Checks if a v23 identity has access to a certain request",
-		ArgsName: "[--filters filters] [--identity identity] <dataset> <version> <tableset>",
+		ArgsName: "[--filters filters] [--identity identity] [--explain] <dataset> <version> <tableset>",
 	}
 	cmd.Flags.StringVar(&filtersFlag, "filters", "", "Filters to use in a comma-separated string.")
 	cmd.Flags.StringVar(&identityFlag, "identity", "", "identity string to be used to check access, if not the user making the request")
+	cmd.Flags.BoolVar(&explainFlag, "explain", false, "Explain the decision: show the caller's blessings, the ACL patterns of the objects the server is expected to consult (a best-effort guide), the pattern missing from each denying ACL and which filters would have been allowed.")
 	return cmd
 }
 
@@ -191,23 +293,31 @@ func cmdTidyset() *cmdline.Command {
 		Name:     "tidyset",
 		Short:    "Queries for the Tidyset based on the dataset, version, tableset, and filters.",
 		Long:     "Queries for the Tidyset based on the dataset, version, tableset, and filters.",
-		ArgsName: "[--filters filters] <dataset> <version> <tableset>",
+		ArgsName: "[--filters filters] [--snapshot snapshot] [--as-of time] [--strict] <dataset> <version> <tableset>",
 	}
 	cmd.Flags.StringVar(&filtersFlag, "filters", "", "Filters to use in a comma-separated string.")
+	addSnapshotFlag(cmd)
+	addAsOfFlag(cmd)
+	addStrictFlag(cmd)
 	cmd.Flags.StringVar(&materializeFlag, "materialize", "", "Filters to materialize in a comma-separated string.")
 	cmd.Flags.StringVar(&outputFlag, "o", "", "Path to output dataset. Will write to tidydata cache by default.")
+	addVerifyFlags(cmd)
+	addOfflineFlag(cmd)
+	addProgressFlag(cmd)
 	return cmd
 }
 
 func runAddVersion(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupAuditLog(); err != nil {
+		return err
+	}
 	if len(args) != 2 {
 		return errors.New("need exactly 2 arguments: <dataset> <version>")
 	}
 	dataset := args[0]
 	version := args[1]
-	state := vdl.StateGenerating
-	return client.AddVersion(dataset, version, state)
+	return client.AddVersion(ctx, dataset, version)
 }
 
 func cmdAddVersion() *cmdline.Command {
@@ -222,7 +332,10 @@ func cmdAddVersion() *cmdline.Command {
 }
 
 func runUpdatePublishState(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupAuditLog(); err != nil {
+		return err
+	}
 	if len(args) != 3 {
 		return errors.New("need exactly 3 arguments: <dataset> <version> <state>")
 	}
@@ -233,7 +346,10 @@ func runUpdatePublishState(ctx *context.T, env *cmdline.Env, args []string) erro
 	if err != nil {
 		return fmt.Errorf("couldn't parse state %v: %v", stateStr, err)
 	}
-	return client.UpdateVersionState(dataset, version, state)
+	if err := setupApprovalStore(false); err != nil {
+		return err
+	}
+	return client.UpdateVersionState(ctx, dataset, version, state)
 }
 
 func cmdUpdatePublishState() *cmdline.Command {
@@ -241,21 +357,24 @@ func cmdUpdatePublishState() *cmdline.Command {
 		Runner:   v23cmd.RunnerFunc(runUpdatePublishState),
 		Name:     "update",
 		Short:    "update publish state",
-		Long:     "Update an existing version. Version for s3://<bucket>/<version-key> must already exist.",
+		Long:     "Update an existing version. Version for s3://<bucket>/<version-key> must already exist. Moving a version to published or above requires a second identity's approval, so must instead be requested with version request-publish; without an approval store it is refused.",
 		ArgsName: "<dataset> <version-key> <version state>",
 	}
 	return cmd
 }
 
 func runUpdateDescription(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupAuditLog(); err != nil {
+		return err
+	}
 	if len(args) != 3 {
 		return errors.New("need exactly 3 arguments: <dataset> <version> <description>")
 	}
 	dataset := args[0]
 	version := args[1]
 	desc := args[2]
-	return client.UpdateVersionDescription(dataset, version, desc)
+	return client.UpdateVersionDescription(ctx, dataset, version, desc)
 }
 
 func cmdUpdateDescription() *cmdline.Command {
@@ -270,13 +389,19 @@ func cmdUpdateDescription() *cmdline.Command {
 }
 
 func runRemoveVersionAlias(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupAuditLog(); err != nil {
+		return err
+	}
 	if len(args) != 2 {
 		return errors.New("need exactly 2 arguments: <dataset> <alias>")
 	}
 	dataset := args[0]
 	alias := args[1]
-	return client.RemoveVersionAlias(dataset, alias)
+	if err := setupAliasPolicy(); err != nil {
+		return err
+	}
+	return reportProtection(env, client.RemoveVersionAlias(ctx, dataset, alias))
 }
 
 func cmdRemoveVersionAlias() *cmdline.Command {
@@ -284,21 +409,28 @@ func cmdRemoveVersionAlias() *cmdline.Command {
 		Runner:   v23cmd.RunnerFunc(runRemoveVersionAlias),
 		Name:     "remove-alias",
 		Short:    "remove an alias",
-		Long:     "Remove an alias.",
-		ArgsName: "<dataset> <alias>",
+		Long:     "Remove an alias. Protected aliases, listed in the client configuration, can only be removed with --force and a --reason.",
+		ArgsName: "[--force --reason reason] <dataset> <alias>",
 	}
+	addProtectionFlags(cmd)
 	return cmd
 }
 
 func runUpdateVersionAlias(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupAuditLog(); err != nil {
+		return err
+	}
 	if len(args) != 3 {
 		return errors.New("need exactly 3 arguments: <dataset> <alias> <new_alias>")
 	}
 	dataset := args[0]
 	alias := args[1]
 	newAlias := args[2]
-	return client.UpdateVersionAlias(dataset, alias, newAlias)
+	if err := setupAliasPolicy(); err != nil {
+		return err
+	}
+	return reportProtection(env, client.UpdateVersionAlias(ctx, dataset, alias, newAlias))
 }
 
 func cmdUpdateVersionAlias() *cmdline.Command {
@@ -306,21 +438,28 @@ func cmdUpdateVersionAlias() *cmdline.Command {
 		Runner:   v23cmd.RunnerFunc(runUpdateVersionAlias),
 		Name:     "update-alias",
 		Short:    "update an alias",
-		Long:     "Update an alias to a different name.",
-		ArgsName: "<dataset> <alias> <new_alias>",
+		Long:     "Update an alias to a different name. Protected aliases, listed in the client configuration, can only be changed with --force and a --reason.",
+		ArgsName: "[--force --reason reason] <dataset> <alias> <new_alias>",
 	}
+	addProtectionFlags(cmd)
 	return cmd
 }
 
 func runAddAlias(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupAuditLog(); err != nil {
+		return err
+	}
 	if len(args) != 3 {
 		return errors.New("need exactly 3 arguments: <dataset> <version> <alias>")
 	}
 	dataset := args[0]
 	version := args[1]
 	alias := args[2]
-	return client.AddVersionAlias(dataset, version, alias)
+	if err := setupAliasPolicy(); err != nil {
+		return err
+	}
+	return reportProtection(env, client.AddVersionAlias(ctx, dataset, version, alias))
 }
 
 func cmdAddAlias() *cmdline.Command {
@@ -328,9 +467,10 @@ func cmdAddAlias() *cmdline.Command {
 		Runner:   v23cmd.RunnerFunc(runAddAlias),
 		Name:     "add-alias",
 		Short:    "add an alias",
-		Long:     "Add an alias. Links to data references data at s3://<bucket>/<version>.",
-		ArgsName: "<dataset> <version-key> <alias>",
+		Long:     "Add an alias. Links to data references data at s3://<bucket>/<version>. Protected aliases, listed in the client configuration, can only be moved with --force and a --reason.",
+		ArgsName: "[--force --reason reason] <dataset> <version-key> <alias>",
 	}
+	addProtectionFlags(cmd)
 	return cmd
 }
 
@@ -346,6 +486,12 @@ func cmdVersion() *cmdline.Command {
 			cmdUpdateDescription(),
 			cmdRemoveVersionAlias(),
 			cmdUpdateVersionAlias(),
+			cmdUndo(),
+			cmdRequestPublish(),
+			cmdApprove(),
+			cmdPending(),
+			cmdDeprecate(),
+			cmdSetLineage(),
 		},
 	}
 	cmd.Flags.StringVar(&filtersFlag, "filters", "", "Filters to use in a comma-separated string.")
@@ -353,8 +499,11 @@ func cmdVersion() *cmdline.Command {
 }
 
 func runListDatasets(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
-	datasets, err := client.ListDatasets()
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
+	datasets, err := client.ListDatasets(ctx)
 	if err == nil {
 		fmt.Println("Datasets:")
 		fmt.Println(strings.Join(datasets, "\n"))
@@ -369,11 +518,15 @@ func cmdListDatasets() *cmdline.Command {
 		Long:   "lists all available datasets",
 		Runner: v23cmd.RunnerFunc(runListDatasets),
 	}
+	addOfflineFlag(cmd)
 	return cmd
 }
 
 func runListVersions(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
 	if len(args) != 1 {
 		return errors.New("need exactly 1 argument: <dataset>")
 	}
@@ -382,73 +535,115 @@ func runListVersions(ctx *context.T, env *cmdline.Env, args []string) error {
 	if err != nil {
 		return fmt.Errorf("couldn't parse publish state %v: %v", publishStateStrFlag, err)
 	}
-	var versionStrings, aliasStrings, stateStrings, descriptionStrings []string
-	if withAliasesFlag {
-		versions, err := client.ListAliasedVersions(dataset)
-		if err != nil {
+	req := tidydata.VersionsRequest{
+		Dataset:        dataset,
+		MinState:       state,
+		WithAlias:      withAliasesFlag,
+		Sort:           sortFlag,
+		LatestPerState: latestPerStateFlag,
+		Offset:         offsetFlag,
+		Limit:          limitFlag,
+	}
+	if sinceFlag != "" {
+		if req.Since, err = tidydata.ParseTime(sinceFlag); err != nil {
+			return err
+		}
+	}
+	if untilFlag != "" {
+		if req.Until, err = tidydata.ParseTime(untilFlag); err != nil {
 			return err
 		}
+	}
+	if err := setupVersionHistory(); err != nil {
+		return err
+	}
+	versions, err := client.ListVersions(ctx, req)
+	if err != nil {
+		return err
+	}
+	if formatFlag == "json" {
+		return writeJSON(env.Stdout, versions)
+	}
+	if withAliasesFlag {
+		var versionStrings, aliasStrings, stateStrings, descriptionStrings, firstSeenStrings, stateSeenStrings []string
 		for _, v := range versions {
-			if v.State >= state {
-				versionStrings = append(versionStrings, v.Version)
-				aliasStrings = append(aliasStrings, v.Alias)
-				stateStrings = append(stateStrings, v.State.String())
-				descriptionStrings = append(descriptionStrings, v.Description)
-			}
+			versionStrings = append(versionStrings, v.Version)
+			aliasStrings = append(aliasStrings, v.Alias)
+			stateStrings = append(stateStrings, v.State.String())
+			descriptionStrings = append(descriptionStrings, v.Description)
+			firstSeenStrings = append(firstSeenStrings, formatSeen(v.FirstSeen))
+			stateSeenStrings = append(stateSeenStrings, formatSeen(v.StateSeen))
 		}
 		df := dataframe.New("aliased_versions",
 			dataframe.StringSeries(aliasStrings, "alias"),
 			dataframe.StringSeries(versionStrings, "version"),
 			dataframe.StringSeries(stateStrings, "publish_state"),
-			dataframe.StringSeries(descriptionStrings, "description"))
+			dataframe.StringSeries(descriptionStrings, "description"),
+			dataframe.StringSeries(firstSeenStrings, "first_seen"),
+			dataframe.StringSeries(stateSeenStrings, "state_seen"))
 		fmt.Println(df)
 	} else {
-		versions, err := client.ListVersionsAt(dataset, state)
-		if err != nil {
-			return err
-		}
-		for _, v := range versions {
-			versionStrings = append(versionStrings, v.Version)
-			stateStrings = append(stateStrings, v.State.String())
-		}
-		df := dataframe.New("versions",
-			dataframe.StringSeries(versionStrings, "version"),
-			dataframe.StringSeries(stateStrings, "publish_state"))
-		_ = df.Sort(dataframe.ColSort{
-			"version",
-			false,
-			false,
-		})
 		fmt.Println("versions:")
-		for i := 0; i < df.NumRows(); i++ {
-			fmt.Printf("%v: %v \n", df.MustAt(i, "version").String(), df.MustAt(i, "publish_state").String())
+		for _, v := range versions {
+			fmt.Printf("%v: %v \n", v.Version, v.State.String())
 		}
 	}
 	return nil
 }
 
+// formatSeen formats a time at which a version was observed, or returns ""
+// if it has not been, as when there is no local version history.
+func formatSeen(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
 func cmdListVersions() *cmdline.Command {
 	cmd := &cmdline.Command{
-		Name:     "versions",
-		Short:    "lists available versions",
-		Long:     "lists available versions",
-		ArgsName: "<dataset>",
+		Name:  "versions",
+		Short: "lists available versions",
+		Long: `lists available versions
+
+The tidy server does not record when versions were created or changed state,
+so the first_seen and state_seen times, shown with --with_alias and in JSON
+output, are those at which this client first observed the version and its
+current state, kept in a local version history. They are not the times at
+which the version was created or changed state on the server, and are empty
+for versions this client has not observed before.
+
+By default only versions with aliases are listed, one row per alias, as the
+tool always has; scripts rely on this. Pass --with_alias=false to list every
+version at or above --publish_state.`,
+		ArgsName: "[--sort natural|first_seen|state_seen] [--since time] [--until time] [--latest_per_state] [--offset n] [--limit n] [--format text|json] <dataset>",
 		Runner:   v23cmd.RunnerFunc(runListVersions),
 	}
-	cmd.Flags.BoolVar(&withAliasesFlag, "with_alias", true, "only show versions with aliases")
+	cmd.Flags.BoolVar(&withAliasesFlag, "with_alias", true, "only show versions with aliases, with their aliases, descriptions and seen times; set to false to list every version")
 	cmd.Flags.StringVar(&publishStateStrFlag, "publish_state", "tested", "minimum publish state")
+	cmd.Flags.StringVar(&sortFlag, "sort", "", "sort order: natural, first_seen or state_seen; defaults to server order with --with_alias and natural order otherwise")
+	cmd.Flags.StringVar(&sinceFlag, "since", "", "only show versions first seen at or after this time (RFC 3339 or YYYY-MM-DD)")
+	cmd.Flags.StringVar(&untilFlag, "until", "", "only show versions first seen before this time (RFC 3339 or YYYY-MM-DD)")
+	cmd.Flags.BoolVar(&latestPerStateFlag, "latest_per_state", false, "only show the last version, in sort order, of each publish state")
+	cmd.Flags.IntVar(&offsetFlag, "offset", 0, "number of versions to skip")
+	cmd.Flags.IntVar(&limitFlag, "limit", 0, "maximum number of versions to show; 0 shows all")
+	cmd.Flags.StringVar(&formatFlag, "format", "text", "output format: text or json")
 
+	addOfflineFlag(cmd)
 	return cmd
 }
 
 func runListTablesets(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
 	if len(args) != 2 {
 		return errors.New("need exactly 2 arguments: <dataset> <version>")
 	}
 	dataset := args[0]
 	version := args[1]
-	tablesets, err := client.ListTablesets(dataset, version)
+	tablesets, err := client.ListTablesets(ctx, dataset, version)
 	if err == nil {
 		fmt.Println("Tablesets:")
 		fmt.Println(strings.Join(tablesets, "\n"))
@@ -464,17 +659,21 @@ func cmdListTablesets() *cmdline.Command {
 		ArgsName: "<dataset> <version>",
 		Runner:   v23cmd.RunnerFunc(runListTablesets),
 	}
+	addOfflineFlag(cmd)
 	return cmd
 }
 
 func runListFilters(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
 	if len(args) != 2 {
 		return errors.New("need exactly 2 arguments: <dataset> <version>")
 	}
 	dataset := args[0]
 	version := args[1]
-	filters, err := client.ListFilters(dataset, version)
+	filters, err := client.ListFilters(ctx, dataset, version)
 	if err != nil {
 		return err
 	}
@@ -493,6 +692,7 @@ func cmdListFilters() *cmdline.Command {
 		ArgsName: "<dataset> <version>",
 		Runner:   v23cmd.RunnerFunc(runListFilters),
 	}
+	addOfflineFlag(cmd)
 	return cmd
 }
 
@@ -504,18 +704,22 @@ func cmdListTables() *cmdline.Command {
 		ArgsName: "<dataset> <version> <tableset>",
 		Runner:   v23cmd.RunnerFunc(runListTables),
 	}
+	addOfflineFlag(cmd)
 	return cmd
 }
 
 func runListTables(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
 	if len(args) != 3 {
 		return errors.New("need exactly 3 arguments: <dataset> <version> <tableset>")
 	}
 	dataset := args[0]
 	version := args[1]
 	tableset := args[2]
-	tables, err := client.ListTables(dataset, version, tableset)
+	tables, err := client.ListTables(ctx, dataset, version, tableset)
 	if err != nil {
 		return err
 	}
@@ -534,17 +738,21 @@ func cmdListAliases() *cmdline.Command {
 		ArgsName: "<dataset> <version>",
 		Runner:   v23cmd.RunnerFunc(runListAliases),
 	}
+	addOfflineFlag(cmd)
 	return cmd
 }
 
 func runListAliases(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
 	if len(args) != 2 {
 		return fmt.Errorf("need exactly two arguments: <dataset> <version>")
 	}
 	dataset := args[0]
 	version := args[1]
-	aliases, err := client.ListAliases(dataset, version)
+	aliases, err := client.ListAliases(ctx, dataset, version)
 	if err == nil {
 		if len(aliases) == 0 {
 			fmt.Printf("No aliases found for dataset %s and version %s\n", dataset, version)
@@ -562,27 +770,40 @@ func cmdListSnapshots() *cmdline.Command {
 	cmd := &cmdline.Command{
 		Name:     "snapshots",
 		Short:    "list clinical data snapshots for a given dataset",
-		Long:     "list clinical data snapshots for a given dataset",
-		ArgsName: "<dataset> <version>",
+		Long:     "list clinical data snapshots for a given dataset, or a version of it, with each snapshot's date, source system and the versions that include it",
+		ArgsName: "<dataset> [version]",
 		Runner:   v23cmd.RunnerFunc(runListSnapshots),
 	}
+	cmd.Flags.StringVar(&publishStateStrFlag, "publish_state", "tested", "minimum publish state of versions searched for snapshots")
+	cmd.Flags.StringVar(&formatFlag, "format", "text", "Output format: text or json.")
+	addOfflineFlag(cmd)
 	return cmd
 }
 
 func runListSnapshots(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
-	if len(args) != 2 {
-		return fmt.Errorf("need exactly two arguments: <dataset> <version>")
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
 	}
-	dataset := args[0]
-	version := args[1]
-	snapshots, err := client.ListSnapshots(dataset, version)
-	if err == nil {
-		for _, s := range snapshots {
-			fmt.Println(s)
-		}
+	if len(args) != 1 && len(args) != 2 {
+		return fmt.Errorf("need one or two arguments: <dataset> [version]")
 	}
-	return err
+	state, err := vdl.StateFromString(publishStateStrFlag)
+	if err != nil {
+		return fmt.Errorf("couldn't parse publish state %v: %v", publishStateStrFlag, err)
+	}
+	req := tidydata.SnapshotsRequest{Dataset: args[0], MinState: state}
+	if len(args) == 2 {
+		req.Version = args[1]
+	}
+	snapshots, err := client.ListSnapshotDetails(ctx, req)
+	if err != nil {
+		return err
+	}
+	if formatFlag == "json" {
+		return writeJSON(env.Stdout, snapshots)
+	}
+	return printSnapshots(env, snapshots)
 }
 
 func cmdList() *cmdline.Command {
@@ -605,12 +826,15 @@ func cmdList() *cmdline.Command {
 }
 
 func runDescribeDataset(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
 	if len(args) != 1 {
 		return errors.New("need exactly 1 argument: <dataset>")
 	}
 	dataset := args[0]
-	description, err := client.DescribeDataset(dataset)
+	description, err := client.DescribeDataset(ctx, dataset)
 	if err == nil {
 		fmt.Println(description)
 	}
@@ -625,17 +849,28 @@ func cmdDescribeDataset() *cmdline.Command {
 		ArgsName: "<dataset>",
 		Runner:   v23cmd.RunnerFunc(runDescribeDataset),
 	}
+	addOfflineFlag(cmd)
 	return cmd
 }
 
 func runDescribeVersion(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
+	args, err := resolveVersionArgs(ctx, env, args, 2)
+	if err != nil {
+		return err
+	}
 	if len(args) != 2 {
 		return errors.New("need exactly 2 arguments: <dataset> <version>")
 	}
+	if err := warnDeprecated(ctx, env, args[0], args[1]); err != nil {
+		return err
+	}
 	dataset := args[0]
 	version := args[1]
-	description, err := client.DescribeVersion(dataset, version)
+	description, err := client.DescribeVersion(ctx, dataset, version)
 	if err == nil {
 		fmt.Println(description)
 	}
@@ -647,21 +882,35 @@ func cmdDescribeVersion() *cmdline.Command {
 		Name:     "version",
 		Short:    "describes a version of a dataset",
 		Long:     "describes a version of a dataset",
-		ArgsName: "<dataset> <version>",
+		ArgsName: "[--snapshot snapshot] [--as-of time] [--strict] <dataset> <version>",
 		Runner:   v23cmd.RunnerFunc(runDescribeVersion),
 	}
+	addSnapshotFlag(cmd)
+	addAsOfFlag(cmd)
+	addStrictFlag(cmd)
+	addOfflineFlag(cmd)
 	return cmd
 }
 
 func runDescribeTableset(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
+	args, err := resolveVersionArgs(ctx, env, args, 3)
+	if err != nil {
+		return err
+	}
 	if len(args) != 3 {
 		return errors.New("need exactly 3 arguments: <dataset> <version> <tableset>")
 	}
+	if err := warnDeprecated(ctx, env, args[0], args[1]); err != nil {
+		return err
+	}
 	dataset := args[0]
 	version := args[1]
 	tableset := args[2]
-	description, err := client.DescribeTableset(dataset, version, tableset)
+	description, err := client.DescribeTableset(ctx, dataset, version, tableset)
 	if err == nil {
 		fmt.Println(description)
 	}
@@ -673,26 +922,40 @@ func cmdDescribeTableset() *cmdline.Command {
 		Name:     "tableset",
 		Short:    "describe a tableset for a dataset and version",
 		Long:     "describe a tableset for a dataset and version",
-		ArgsName: "<dataset> <version> <tableset>",
+		ArgsName: "[--snapshot snapshot] [--as-of time] [--strict] <dataset> <version> <tableset>",
 		Runner:   v23cmd.RunnerFunc(runDescribeTableset),
 	}
+	addSnapshotFlag(cmd)
+	addAsOfFlag(cmd)
+	addStrictFlag(cmd)
+	addOfflineFlag(cmd)
 	return cmd
 }
 
 func runDescribeFilter(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
+	args, err := resolveVersionArgs(ctx, env, args, 3)
+	if err != nil {
+		return err
+	}
 	if len(args) != 3 {
 		return errors.New("need exactly 3 arguments: <dataset> <version> <filter>")
 	}
+	if err := warnDeprecated(ctx, env, args[0], args[1]); err != nil {
+		return err
+	}
 	dataset := args[0]
 	version := args[1]
 	filter := args[2]
-	description, queryString, err := client.DescribeFilter(dataset, version, filter)
+	description, err := client.DescribeFilter(ctx, dataset, version, filter)
 	if err == nil {
 		fmt.Println("Query String:")
-		fmt.Println(queryString)
+		fmt.Println(description.QueryString)
 		fmt.Println("Description:")
-		fmt.Println(description)
+		fmt.Println(description.Description)
 	}
 	return err
 }
@@ -702,22 +965,36 @@ func cmdDescribeFilter() *cmdline.Command {
 		Name:     "filter",
 		Short:    "describe a filter for a dataset and version",
 		Long:     "describe a filter for a dataset and version",
-		ArgsName: "<dataset> <version> <filter>",
+		ArgsName: "[--snapshot snapshot] [--as-of time] [--strict] <dataset> <version> <filter>",
 		Runner:   v23cmd.RunnerFunc(runDescribeFilter),
 	}
+	addSnapshotFlag(cmd)
+	addAsOfFlag(cmd)
+	addStrictFlag(cmd)
+	addOfflineFlag(cmd)
 	return cmd
 }
 
 func runDescribeTable(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
+	args, err := resolveVersionArgs(ctx, env, args, 4)
+	if err != nil {
+		return err
+	}
 	if len(args) != 4 {
 		return errors.New("need exactly 4 arguments: <dataset> <version> <tableset> <table>")
 	}
+	if err := warnDeprecated(ctx, env, args[0], args[1]); err != nil {
+		return err
+	}
 	dataset := args[0]
 	version := args[1]
 	tableset := args[2]
 	table := args[3]
-	info, err := client.DescribeTable(dataset, version, tableset, table)
+	info, err := client.DescribeTable(ctx, dataset, version, tableset, table)
 	if err != nil {
 		return err
 	}
@@ -735,9 +1012,13 @@ func cmdDescribeTable() *cmdline.Command {
 		Name:     "table",
 		Short:    "describe a table for a tableset in a dataset and version",
 		Long:     "describe a table for a tableset in a dataset and version",
-		ArgsName: "<dataset> <version> <tableset> <table>",
+		ArgsName: "[--snapshot snapshot] [--as-of time] [--strict] <dataset> <version> <tableset> <table>",
 		Runner:   v23cmd.RunnerFunc(runDescribeTable),
 	}
+	addSnapshotFlag(cmd)
+	addAsOfFlag(cmd)
+	addStrictFlag(cmd)
+	addOfflineFlag(cmd)
 	return cmd
 }
 
@@ -746,30 +1027,44 @@ func cmdDescribeColumn() *cmdline.Command {
 		Name:     "column",
 		Short:    "describe a column for a table in a dataset and version",
 		Long:     "describe a column for a table in a dataset and version. Currently only supported for clinical tables.",
-		ArgsName: "<dataset> <version> <table> <column>",
+		ArgsName: "[--snapshot snapshot] [--as-of time] [--strict] <dataset> <version> <table> <column>",
 		Runner:   v23cmd.RunnerFunc(runDescribeColumn),
 	}
+	addSnapshotFlag(cmd)
+	addAsOfFlag(cmd)
+	addStrictFlag(cmd)
+	addOfflineFlag(cmd)
 	return cmd
 }
 
 func runDescribeColumn(ctx *context.T, env *cmdline.Env, args []string) error {
-	client = tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
+	args, err := resolveVersionArgs(ctx, env, args, 4)
+	if err != nil {
+		return err
+	}
 	if len(args) != 4 {
 		return errors.New("need exactly 4 arguments: <dataset> <version> <table> <column>")
 	}
+	if err := warnDeprecated(ctx, env, args[0], args[1]); err != nil {
+		return err
+	}
 	dataset := args[0]
 	version := args[1]
 	table := args[2]
 	column := args[3]
-	description, err := client.DescribeColumn(dataset, version, table, column)
+	description, err := client.DescribeColumn(ctx, dataset, version, table, column)
 	if err != nil {
 		return err
 	}
 	fmt.Printf("Column %s: \n", column)
-	fmt.Printf("Description: %s \n", description[0])
-	if description[1] != "" {
+	fmt.Printf("Description: %s \n", description.Description)
+	if description.Rule != "" {
 		fmt.Println("Rule:")
-		fmt.Println(description[1])
+		fmt.Println(description.Rule)
 	}
 	return nil
 }
@@ -792,24 +1087,36 @@ func cmdDescribe() *cmdline.Command {
 }
 
 func runPreprocessedData(ctx *context.T, env *cmdline.Env, args []string) error {
-	client := tidy.NewTidyClient(ctx, addressFlag)
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
 	if len(args) != 2 {
 		return errors.New("need exactly 2 arguments: <dataset> <version>")
 	}
-	path, version, err := client.GetPreprocessedData(args[0], args[1])
+	if err := warnDeprecated(ctx, env, args[0], args[1]); err != nil {
+		return err
+	}
+	expected, err := setupVerification()
 	if err != nil {
 		return err
 	}
-	if outputFlag != "" {
-		data, err := ioutil.ReadFile(path)
-		if err != nil {
-			return err
-		}
-		if err = ioutil.WriteFile(outputFlag, data, os.ModePerm); err != nil {
-			return err
-		}
+	if err := setupFetchLog(); err != nil {
+		return err
+	}
+	if err := setupProgress(env); err != nil {
+		return err
 	}
-	return outputPathAndVersion(env, path, version)
+	fetched, err := client.PreprocessedData(ctx, tidydata.PreprocessedRequest{
+		Dataset:  args[0],
+		Version:  args[1],
+		Output:   outputFlag,
+		Expected: expected,
+	})
+	if err != nil {
+		return err
+	}
+	return outputPathAndVersion(env, fetched.Path, fetched.Version)
 }
 
 func cmdPreprocessed() *cmdline.Command {
@@ -817,10 +1124,14 @@ func cmdPreprocessed() *cmdline.Command {
 		Name:     "preprocessed-data",
 		Short:    "Fetches preprocessed data for given dataset and version",
 		Long:     "Fetches preprocessed data for given dataset and version",
-		ArgsName: "<dataset> <version>",
+		ArgsName: "[--strict] <dataset> <version>",
 		Runner:   v23cmd.RunnerFunc(runPreprocessedData),
 	}
 	cmd.Flags.StringVar(&outputFlag, "o", "", "Path to output dataset. Will write to tidydata cache by default.")
+	addVerifyFlags(cmd)
+	addStrictFlag(cmd)
+	addOfflineFlag(cmd)
+	addProgressFlag(cmd)
 	return cmd
 }
 
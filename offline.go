@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/x/lib/cmdline"
+)
+
+// setupMetadataCache makes the global client cache metadata responses in
+// the local state directory, serving them from there with --offline, and
+// warns on env.Stderr about each stale response it serves. The cache is
+// only needed with --offline: otherwise, if it can't be set up, for
+// example because $HOME is unset or the client configuration is
+// malformed, setupMetadataCache warns and leaves the client without one.
+func setupMetadataCache(env *cmdline.Env) error {
+	cache, err := metadataCache(env)
+	if err != nil {
+		if offlineFlag {
+			return err
+		}
+		fmt.Fprintf(env.Stderr, "warning: not caching metadata: %v\n", err)
+		return nil
+	}
+	client.SetMetadataCache(cache)
+	return nil
+}
+
+// metadataCache returns the metadata cache in the local state directory.
+func metadataCache(env *cmdline.Env) (*tidydata.MetadataCache, error) {
+	dir, err := localPath("metadata")
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	ttls, err := cfg.metadataTTLs()
+	if err != nil {
+		return nil, err
+	}
+	warned := map[string]bool{}
+	return &tidydata.MetadataCache{
+		Dir:     dir,
+		TTLs:    ttls,
+		Offline: offlineFlag,
+		Stale: func(r tidydata.CachedResponse, age, ttl time.Duration) {
+			if !warned[r.String()] {
+				warned[r.String()] = true
+				fmt.Fprintf(env.Stderr, "warning: offline: %s was cached %s ago, longer than its %s TTL, and may be out of date\n", r, age.Round(time.Minute), ttl)
+			}
+		},
+	}, nil
+}
+
+// addOfflineFlag adds the --offline flag to a command that can be served
+// from the local caches.
+func addOfflineFlag(cmd *cmdline.Command) {
+	cmd.Flags.BoolVar(&offlineFlag, "offline", false, "Don't contact the server: serve metadata from the responses cached by earlier commands, and data from earlier fetches, warning about any that are stale.")
+}
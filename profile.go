@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runProfile(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) != 3 && len(args) != 4 {
+		return errors.New("need 3 or 4 arguments: <dataset> <version> <tableset> [table]")
+	}
+	req := tidydata.ProfileRequest{
+		Dataset:  args[0],
+		Version:  args[1],
+		Tableset: args[2],
+		Path:     dbFlag,
+		Options:  tidydata.DefaultProfileOptions,
+	}
+	if len(args) == 4 {
+		req.Table = args[3]
+	}
+	req.Options.TopN = topFlag
+	req.Options.Bins = binsFlag
+	profiles, err := client.Profile(ctx, req)
+	if err != nil {
+		return err
+	}
+	if formatFlag == "json" {
+		return writeJSON(env.Stdout, profiles)
+	}
+	return tidydata.WriteProfiles(env.Stdout, profiles, formatFlag)
+}
+
+func cmdProfile() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runProfile),
+		Name:     "profile",
+		Short:    "Profiles the columns of a tidyset",
+		Long:     "Reports the null rate, distinct count, min and max, most frequent values and, for numeric columns, the mean and a histogram of every column of the tables of a tidyset. Profiles the cached tidyset, fetching it if needed, unless --db is given. Rows are streamed so large tables are profiled in bounded memory.",
+		ArgsName: "[--format text|json|html] [--db file] <dataset> <version> <tableset> [table]",
+	}
+	cmd.Flags.StringVar(&formatFlag, "format", "text", "Output format: text, json or html.")
+	cmd.Flags.StringVar(&dbFlag, "db", "", "Local tidyset database to profile instead of the cached tidyset.")
+	cmd.Flags.IntVar(&topFlag, "top", 10, "Number of most frequent values to report per column.")
+	cmd.Flags.IntVar(&binsFlag, "bins", 10, "Number of histogram bins for numeric columns.")
+	return cmd
+}
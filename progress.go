@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/x/lib/cmdline"
+)
+
+// setupProgress makes the global client report the progress of fetches on
+// env.Stderr according to the --progress flag: as a status line redrawn in
+// place with text, as one JSON event per line with json, and not at all
+// with none. The default, auto, is text if env.Stderr is a terminal and
+// none otherwise.
+func setupProgress(env *cmdline.Env) error {
+	var report func(tidydata.ProgressEvent)
+	switch progressFlag {
+	case "auto":
+		if !isTerminal(env.Stderr) {
+			return nil
+		}
+		report = textProgress(env.Stderr, true)
+	case "text":
+		report = textProgress(env.Stderr, isTerminal(env.Stderr))
+	case "json":
+		enc := json.NewEncoder(env.Stderr)
+		report = func(e tidydata.ProgressEvent) { enc.Encode(e) }
+	case "none":
+		return nil
+	default:
+		return fmt.Errorf("unsupported --progress %q: must be auto, text, json or none", progressFlag)
+	}
+	client.SetProgress(&tidydata.Progress{Report: report})
+	return nil
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// textProgress returns a function that writes progress events to w as
+// status lines, redrawing a single line if inPlace is set.
+func textProgress(w io.Writer, inPlace bool) func(tidydata.ProgressEvent) {
+	return func(e tidydata.ProgressEvent) {
+		elapsed := time.Duration(e.ElapsedMS) * time.Millisecond
+		label := strings.TrimSpace(strings.Join([]string{e.Dataset, e.Requested, e.Tableset}, " "))
+		var line string
+		switch {
+		case e.Phase == tidydata.PhaseDone && e.Error != "":
+			line = fmt.Sprintf("%s: failed after %s", label, elapsed.Round(time.Second))
+		case e.Phase == tidydata.PhaseDone:
+			line = fmt.Sprintf("%s: done: %s in %s", label, formatBytes(e.Bytes), elapsed.Round(time.Second))
+		case e.Phase == tidydata.PhaseFetching:
+			line = fmt.Sprintf("%s: fetching, %s elapsed", label, elapsed.Round(time.Second))
+			if e.Total > 0 {
+				line += fmt.Sprintf(", about %s", formatBytes(e.Total))
+			}
+			if e.ETAMS > 0 {
+				line += fmt.Sprintf(", ETA %s", (time.Duration(e.ETAMS) * time.Millisecond).Round(time.Second))
+			}
+		default:
+			line = fmt.Sprintf("%s: %s %s", label, e.Phase, formatBytes(e.Bytes))
+			if e.Total > 0 {
+				line += " / " + formatBytes(e.Total)
+			}
+			line += fmt.Sprintf(", %s/s", formatBytes(int64(e.Rate)))
+			if e.ETAMS > 0 {
+				line += fmt.Sprintf(", ETA %s", (time.Duration(e.ETAMS) * time.Millisecond).Round(time.Second))
+			}
+		}
+		switch {
+		case !inPlace:
+			fmt.Fprintln(w, line)
+		case e.Phase == tidydata.PhaseDone:
+			fmt.Fprintf(w, "\r\033[K%s\n", line)
+		default:
+			fmt.Fprintf(w, "\r\033[K%s", line)
+		}
+	}
+}
+
+// formatBytes formats a number of bytes in binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// addProgressFlag adds the --progress flag to a fetching command.
+func addProgressFlag(cmd *cmdline.Command) {
+	cmd.Flags.StringVar(&progressFlag, "progress", "auto", "Report the progress of the fetch on stderr: auto, text, json or none. auto is text if stderr is a terminal and none otherwise.")
+}
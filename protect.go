@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/x/lib/cmdline"
+)
+
+// setupAliasPolicy makes the global client enforce the protected aliases of
+// the client configuration, overridden if --force is set.
+func setupAliasPolicy() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	client.SetAliasPolicy(&cfg.AliasPolicy)
+	if err := setupFetchLog(); err != nil {
+		return err
+	}
+	if forceFlag {
+		if strings.TrimSpace(reasonFlag) == "" {
+			return fmt.Errorf("--force requires a --reason")
+		}
+		client.SetOverride(reasonFlag)
+	}
+	return nil
+}
+
+// reportProtection prints how an alias operation that failed with err would
+// have affected protected aliases, if that is why it failed, and returns
+// err.
+func reportProtection(env *cmdline.Env, err error) error {
+	var perr *tidydata.ProtectedAliasError
+	if !errors.As(err, &perr) {
+		return err
+	}
+	for _, i := range perr.Impacts {
+		fmt.Fprintf(env.Stderr, "alias %s of %s is protected\n", i.Alias, i.Dataset)
+		switch {
+		case i.From == "":
+			fmt.Fprintf(env.Stderr, "  would be created pointing to %s\n", i.To)
+		case i.To == "":
+			fmt.Fprintf(env.Stderr, "  points to %s and would be removed\n", i.From)
+		default:
+			fmt.Fprintf(env.Stderr, "  points to %s and would point to %s\n", i.From, i.To)
+		}
+		if len(i.Consumers) > 0 {
+			fmt.Fprintf(env.Stderr, "  consumers: %s\n", strings.Join(i.Consumers, ", "))
+		}
+		fmt.Fprintf(env.Stderr, "  fetched %d times through this alias from this machine\n", i.LocalFetches)
+	}
+	return err
+}
+
+// addProtectionFlags adds the flags that override alias protection.
+func addProtectionFlags(cmd *cmdline.Command) {
+	cmd.Flags.BoolVar(&forceFlag, "force", false, "Change protected aliases. Requires --reason.")
+	cmd.Flags.StringVar(&reasonFlag, "reason", "", "Reason for changing protected aliases, recorded in the audit log.")
+}
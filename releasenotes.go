@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runGenerateReleaseNotes(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) != 3 {
+		return errors.New("need exactly 3 arguments: <dataset> <old-version> <new-version>")
+	}
+	notes, err := client.GenerateReleaseNotes(ctx, tidydata.ReleaseNotesRequest{
+		Dataset:     args[0],
+		OldVersion:  args[1],
+		NewVersion:  args[2],
+		URLTemplate: releaseNotesURLFlag,
+	})
+	if err != nil {
+		return err
+	}
+	return tidydata.WriteReleaseNotes(env.Stdout, notes, notesFormatFlag)
+}
+
+// addReleaseNotesURLFlag adds the --url-template flag to a release notes
+// command.
+func addReleaseNotesURLFlag(cmd *cmdline.Command) {
+	cmd.Flags.StringVar(&releaseNotesURLFlag, "url-template", tidydata.DefaultReleaseNotesURL, "Template for release notes URLs; {dataset} and {version} are replaced.")
+}
+
+func cmdGenerateReleaseNotes() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runGenerateReleaseNotes),
+		Name:     "generate",
+		Short:    "Generates release notes comparing two versions of a dataset",
+		Long:     "Generates Markdown or HTML release notes from the version description and a structural comparison of the tablesets, tables, columns, row counts and filters of two versions of a dataset.",
+		ArgsName: "[--format markdown|html] [--url-template template] <dataset> <old-version> <new-version>",
+	}
+	cmd.Flags.StringVar(&notesFormatFlag, "format", "markdown", "Output format: markdown or html.")
+	addReleaseNotesURLFlag(cmd)
+	return cmd
+}
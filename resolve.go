@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+// resolveVersionArgs applies the --snapshot and --as-of flags to the
+// arguments of a command whose first two arguments are a dataset and a
+// version; see resolveSnapshotArgs and resolveAsOfArgs.
+func resolveVersionArgs(ctx *context.T, env *cmdline.Env, args []string, want int) ([]string, error) {
+	args, err := resolveSnapshotArgs(ctx, env, args, want)
+	if err != nil {
+		return nil, err
+	}
+	return resolveAsOfArgs(ctx, env, args)
+}
+
+// resolveAsOfArgs replaces the version argument, which may be an alias,
+// with the version it resolved to at the time given by --as-of.
+func resolveAsOfArgs(ctx *context.T, env *cmdline.Env, args []string) ([]string, error) {
+	if asOfFlag == "" || len(args) < 2 {
+		return args, nil
+	}
+	asOf, err := tidydata.ParseTime(asOfFlag)
+	if err != nil {
+		return nil, err
+	}
+	if err := setupVersionHistory(); err != nil {
+		return nil, err
+	}
+	if err := setupFetchLog(); err != nil {
+		return nil, err
+	}
+	version, err := client.ResolveVersionAsOf(ctx, args[0], args[1], asOf)
+	if err != nil {
+		return nil, err
+	}
+	if version != args[1] {
+		fmt.Fprintf(env.Stderr, "using %s %s, which %s pointed to at %s\n", args[0], version, args[1], asOf.Format(time.RFC3339))
+	}
+	return append([]string{args[0], version}, args[2:]...), nil
+}
+
+// addAsOfFlag adds the --as-of flag to a command that resolves or selects
+// a version.
+func addAsOfFlag(cmd *cmdline.Command) {
+	cmd.Flags.StringVar(&asOfFlag, "as-of", "", "Resolve aliases to, and select versions by, what they were at this time (RFC 3339 or YYYY-MM-DD), according to the version history and fetch log of this machine only; the tidy server keeps no history.")
+}
+
+func runResolve(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if err := setupMetadataCache(env); err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return errors.New("need exactly 2 arguments: <dataset> <version>")
+	}
+	if asOfFlag != "" {
+		resolved, err := resolveAsOfArgs(ctx, env, args)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(env.Stdout, resolved[1])
+		return nil
+	}
+	if err := setupVersionHistory(); err != nil {
+		return err
+	}
+	version, err := client.ResolveVersion(ctx, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(env.Stdout, version)
+	return nil
+}
+
+func cmdResolve() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner: v23cmd.RunnerFunc(runResolve),
+		Name:   "resolve",
+		Short:  "Prints the version an alias resolves to",
+		Long: `Prints the version that a version or alias of a dataset resolves to.
+
+With --as-of, prints the version it resolved to at a past time. The tidy server
+keeps no alias history, so past aliases are resolved from the local version
+history, which records aliases whenever versions are listed or resolved, and
+otherwise from the local log of fetches. The command fails if neither records
+what the alias pointed to at that time.`,
+		ArgsName: "[--as-of time] <dataset> <version>",
+	}
+	addAsOfFlag(cmd)
+	addOfflineFlag(cmd)
+	return cmd
+}
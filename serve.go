@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+// gatewayAuthenticator returns the authenticator of gateway callers given
+// by the --client-ca or --token-key flag, configuring tlsConfig to verify
+// client certificates in the former case.
+func gatewayAuthenticator(tlsConfig *tls.Config) (func(*http.Request) (string, error), error) {
+	switch {
+	case clientCAFlag != "" && tokenKeyFlag != "":
+		return nil, errors.New("use either --client-ca or --token-key, not both")
+	case clientCAFlag != "":
+		pem, err := ioutil.ReadFile(clientCAFlag)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", clientCAFlag)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		return tidydata.CertificateIdentity, nil
+	case tokenKeyFlag != "":
+		key, err := readBase64File(tokenKeyFlag)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read token key: %v", err)
+		}
+		return tidydata.TokenIdentity(key)
+	}
+	return nil, errors.New("callers must be authenticated: use --client-ca or --token-key")
+}
+
+func runServe(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) != 0 {
+		return errors.New("serve takes no arguments")
+	}
+	if httpFlag == "" {
+		return errors.New("--http is required")
+	}
+	if tlsCertFlag == "" || tlsKeyFlag == "" {
+		return errors.New("--tls-cert and --tls-key are required")
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	authenticate, err := gatewayAuthenticator(tlsConfig)
+	if err != nil {
+		return err
+	}
+	handler, err := tidydata.NewGateway(ctx, client, tidydata.GatewayOptions{
+		Authenticate: authenticate,
+		Log:          env.Stderr,
+	})
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:              httpFlag,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      writeTimeoutFlag,
+		IdleTimeout:       2 * time.Minute,
+	}
+	fmt.Fprintf(env.Stderr, "serving %s on %s\n", addressFlag, httpFlag)
+	return server.ListenAndServeTLS(tlsCertFlag, tlsKeyFlag)
+}
+
+func cmdServe() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner: v23cmd.RunnerFunc(runServe),
+		Name:   "serve",
+		Short:  "Serves an HTTP/JSON gateway to the tidy server",
+		Long: `Serves list, describe, check-access, resolve and download operations as REST
+endpoints with JSON responses over HTTPS. Calls are made under the server's
+identity, so the caller's identity is checked with check-access on every
+request. Data and tableset metadata are served only if the caller may fetch the
+tableset; preprocessed data only if the caller may fetch every tableset of the
+version; and dataset and version metadata only if the caller may fetch, without
+filters, some tableset of the version or of one of the dataset's versions.
+Listings omit the datasets and versions the caller may not see. An alias is
+resolved once per request and access is checked for the version it resolves to.
+
+Every caller must be authenticated, either by a TLS client certificate
+verified against --client-ca, whose subject common name is the caller's
+blessing, or by a bearer token signed with --token-key, as issued by
+serve token.`,
+		ArgsName: "--http address --tls-cert file --tls-key file (--client-ca file | --token-key file)",
+		Children: []*cmdline.Command{
+			cmdServeToken(),
+		},
+	}
+	cmd.Flags.StringVar(&httpFlag, "http", "", "Address to serve HTTPS on, e.g. :8443.")
+	cmd.Flags.StringVar(&tlsCertFlag, "tls-cert", "", "PEM file containing the server's TLS certificate.")
+	cmd.Flags.StringVar(&tlsKeyFlag, "tls-key", "", "PEM file containing the server's TLS private key.")
+	cmd.Flags.StringVar(&clientCAFlag, "client-ca", "", "PEM file of the CAs that issue client certificates; authenticates callers by certificate.")
+	addTokenKeyFlag(cmd)
+	cmd.Flags.DurationVar(&writeTimeoutFlag, "write-timeout", time.Hour, "Maximum time to serve a request, including materializing and downloading data.")
+	return cmd
+}
+
+// addTokenKeyFlag adds the --token-key flag to a command that signs or
+// checks gateway tokens.
+func addTokenKeyFlag(cmd *cmdline.Command) {
+	cmd.Flags.StringVar(&tokenKeyFlag, "token-key", "", "File containing the base64 key, of at least 32 bytes, that signs bearer tokens; authenticates callers by token.")
+}
+
+func runServeToken(ctx *context.T, env *cmdline.Env, args []string) error {
+	if len(args) != 1 {
+		return errors.New("need exactly 1 argument: <identity>")
+	}
+	if tokenKeyFlag == "" {
+		return errors.New("--token-key is required")
+	}
+	key, err := readBase64File(tokenKeyFlag)
+	if err != nil {
+		return fmt.Errorf("couldn't read token key: %v", err)
+	}
+	token, err := tidydata.SignGatewayToken(key, args[0], time.Now().Add(tokenTTLFlag))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(env.Stdout, token)
+	return nil
+}
+
+func cmdServeToken() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runServeToken),
+		Name:     "token",
+		Short:    "Issues a bearer token for the HTTP gateway",
+		Long:     "Prints a bearer token, signed with --token-key, that authenticates its holder to serve --token-key as the given blessing string.",
+		ArgsName: "--token-key file [--ttl duration] <identity>",
+	}
+	addTokenKeyFlag(cmd)
+	cmd.Flags.DurationVar(&tokenTTLFlag, "ttl", 24*time.Hour, "How long the token is valid.")
+	return cmd
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+)
+
+// resolveSnapshotArgs applies the --snapshot flag to the arguments of a
+// command whose version argument follows the dataset. If the version is
+// omitted, so that args has one fewer than want elements, the newest
+// published version that includes the snapshot is inserted; otherwise the
+// given version must include the snapshot.
+func resolveSnapshotArgs(ctx *context.T, env *cmdline.Env, args []string, want int) ([]string, error) {
+	if snapshotFlag == "" {
+		return args, nil
+	}
+	if len(args) == want {
+		return args, client.CheckVersionSnapshot(ctx, args[0], args[1], snapshotFlag)
+	}
+	if len(args) != want-1 || len(args) == 0 {
+		return args, nil
+	}
+	published, err := vdl.StateFromString("published")
+	if err != nil {
+		return nil, err
+	}
+	version, err := client.VersionForSnapshot(ctx, args[0], snapshotFlag, published)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(env.Stderr, "using %s %s, the newest published version with snapshot %s\n", args[0], version, snapshotFlag)
+	return append([]string{args[0], version}, args[1:]...), nil
+}
+
+// addSnapshotFlag adds the --snapshot flag to a command with a version
+// argument.
+func addSnapshotFlag(cmd *cmdline.Command) {
+	cmd.Flags.StringVar(&snapshotFlag, "snapshot", "", "Clinical snapshot, by name or date, that the version must include. If the version is omitted, the newest published version that includes the snapshot is used.")
+}
+
+// printSnapshots prints snapshot details as a table.
+func printSnapshots(env *cmdline.Env, snapshots []tidydata.Snapshot) error {
+	tw := tabwriter.NewWriter(env.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SNAPSHOT\tDATE\tSOURCE\tVERSIONS")
+	for _, s := range snapshots {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Raw, s.Date, s.Source, strings.Join(s.Versions, ", "))
+	}
+	return tw.Flush()
+}
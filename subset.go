@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"text/tabwriter"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runSubset(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) != 3 {
+		return errors.New("need exactly 3 arguments: <dataset> <version> <tableset>")
+	}
+	req := tidydata.SubsetRequest{
+		Dataset:  args[0],
+		Version:  args[1],
+		Tableset: args[2],
+		Path:     dbFlag,
+		Output:   outputFlag,
+		Key:      keyFlag,
+		Fraction: fractionFlag,
+		Seed:     seedFlag,
+	}
+	if req.Output == "" {
+		req.Output = fmt.Sprintf("%s_%s_%s_subset.db", req.Dataset, req.Version, req.Tableset)
+	}
+	prov, err := client.Subset(ctx, req)
+	if err != nil {
+		return err
+	}
+	if formatFlag == "json" {
+		return writeJSON(env.Stdout, prov)
+	}
+	fmt.Fprintf(env.Stdout, "%s: %d %s values sampled (provenance in %s)\n", prov.Output, prov.Keys, prov.Key, tidydata.ProvenancePath(prov.Output))
+	tw := tabwriter.NewWriter(env.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "table\trows\tsource rows\t\n")
+	for _, t := range prov.Tables {
+		note := ""
+		if !t.Sampled {
+			note = "(copied in full)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", t.Name, t.Rows, t.SourceRows, note)
+	}
+	return tw.Flush()
+}
+
+func cmdSubset() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner: v23cmd.RunnerFunc(runSubset),
+		Name:   "subset",
+		Short:  "Writes a small deterministic sample of a tidyset",
+		Long: `Writes a database with every row, across every table, of a deterministic
+sample of the values of a key column, so that the sample stays consistent
+across tables. The same seed always selects the same subjects. Tables without
+the key column are copied in full. A provenance record describing how the
+sample was derived is written next to the database.`,
+		ArgsName: "[--fraction f] [--key column] [--seed n] [-o file] <dataset> <version> <tableset>",
+	}
+	cmd.Flags.Float64Var(&fractionFlag, "fraction", 0.01, "Fraction of key values to sample.")
+	cmd.Flags.StringVar(&keyFlag, "key", "subject_id", "Column identifying a subject.")
+	cmd.Flags.Int64Var(&seedFlag, "seed", 0, "Seed selecting the sample.")
+	cmd.Flags.StringVar(&outputFlag, "o", "", "Path of the sampled database. Defaults to <dataset>_<version>_<tableset>_subset.db.")
+	cmd.Flags.StringVar(&dbFlag, "db", "", "Local tidyset database to sample instead of the cached tidyset.")
+	cmd.Flags.StringVar(&formatFlag, "format", "text", "Output format: text or json.")
+	return cmd
+}
@@ -0,0 +1,175 @@
+package tidydata
+
+import (
+	"sort"
+	"strings"
+
+	v23 "v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/v23/security"
+	"v.io/v23/services/permissions"
+)
+
+// ACLCheck is the result of checking a set of blessing names against one
+// access list of an object.
+type ACLCheck struct {
+	Tag     string   `json:"tag"`
+	In      []string `json:"in"`
+	NotIn   []string `json:"not_in,omitempty"`
+	Granted bool     `json:"granted"`
+	// ExcludedBy lists the NotIn patterns matched by the blessing names.
+	ExcludedBy []string `json:"excluded_by,omitempty"`
+	// Missing is, if access is not granted and no NotIn pattern excluded
+	// the names, the In pattern that comes closest to being matched by
+	// them.
+	Missing string `json:"missing,omitempty"`
+}
+
+// closestPattern returns the pattern in in that shares the most leading
+// blessing components with one of names, preferring the earliest on ties.
+func closestPattern(in []security.BlessingPattern, names []string) string {
+	best, bestShared := "", -1
+	for _, p := range in {
+		pc := strings.Split(string(p), security.ChainSeparator)
+		for _, name := range names {
+			nc := strings.Split(name, security.ChainSeparator)
+			shared := 0
+			for shared < len(pc) && shared < len(nc) && pc[shared] == nc[shared] {
+				shared++
+			}
+			if shared > bestShared {
+				best, bestShared = string(p), shared
+			}
+		}
+	}
+	return best
+}
+
+// ObjectACLs are the access lists of a named object on the tidy server.
+// The tidy client API does not report which objects the server consults,
+// so the names are inferred from the server's naming layout; see
+// governingObjects.
+type ObjectACLs struct {
+	// Kind describes what the object governs, for example "dataset" or
+	// "filter f".
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Error is set if the object's permissions could not be read.
+	Error string     `json:"error,omitempty"`
+	ACLs  []ACLCheck `json:"acls,omitempty"`
+}
+
+// AccessExplanation explains the outcome of an access check.
+type AccessExplanation struct {
+	Request AccessRequest `json:"request"`
+	// CallerBlessings are the default blessings of the caller's principal.
+	CallerBlessings []string `json:"caller_blessings"`
+	// Objects are the ACLs that govern the request, from the outermost to
+	// the innermost. They are a best-effort reconstruction: only Error
+	// reflects the server's actual decision.
+	Objects []ObjectACLs `json:"objects"`
+	// Error is the result of the access check, or empty if access is
+	// allowed.
+	Error string `json:"error,omitempty"`
+	// AllowedFilters lists the filters, not already in the request, any
+	// one of which would have made a denied request allowed.
+	AllowedFilters []string `json:"allowed_filters,omitempty"`
+	// FilterListError is set if the filters could not be listed.
+	FilterListError string `json:"filter_list_error,omitempty"`
+}
+
+// governingObjects returns the objects, from the outermost to the innermost,
+// whose ACLs are expected to govern req. The names assume the server mounts
+// versions, tablesets and filters below their dataset; the server may check
+// other objects, or none of these, so the result is a best-effort guide.
+func (c *Client) governingObjects(req AccessRequest) []ObjectACLs {
+	objects := []ObjectACLs{
+		{Kind: "dataset", Name: naming.Join(c.address, req.Dataset)},
+		{Kind: "version", Name: naming.Join(c.address, req.Dataset, req.Version)},
+		{Kind: "tableset", Name: naming.Join(c.address, req.Dataset, req.Version, req.Tableset)},
+	}
+	for _, f := range req.Filters {
+		objects = append(objects, ObjectACLs{Kind: "filter " + f, Name: naming.Join(c.address, req.Dataset, req.Version, "filters", f)})
+	}
+	return objects
+}
+
+// callerBlessings returns the names of the default blessings of the
+// principal in ctx.
+func callerBlessings(ctx *context.T) []string {
+	principal := v23.GetPrincipal(ctx)
+	if principal == nil {
+		return nil
+	}
+	blessings, _ := principal.BlessingStore().Default()
+	return security.BlessingNames(principal, blessings)
+}
+
+// ExplainAccess checks req and explains the outcome: the caller's
+// blessings, the ACL patterns that are expected to govern the request and
+// whether they are satisfied by req.Identity, and, if access is denied, which additional
+// filters would have allowed it. The returned error is that of the access
+// check itself, so callers can treat it as CheckAccess.
+func (c *Client) ExplainAccess(ctx *context.T, req AccessRequest) (*AccessExplanation, error) {
+	accessErr := c.CheckAccess(ctx, req)
+	exp := &AccessExplanation{Request: req}
+	exp.CallerBlessings = callerBlessings(ctx)
+
+	names := strings.Split(req.Identity, ",")
+	for _, obj := range c.governingObjects(req) {
+		perms, _, err := permissions.ObjectClient(obj.Name).GetPermissions(ctx)
+		if err != nil {
+			obj.Error = err.Error()
+			exp.Objects = append(exp.Objects, obj)
+			continue
+		}
+		var tags []string
+		for tag := range perms {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			acl := perms[tag]
+			check := ACLCheck{Tag: tag, NotIn: acl.NotIn, Granted: acl.Includes(names...)}
+			for _, p := range acl.In {
+				check.In = append(check.In, string(p))
+			}
+			for _, p := range acl.NotIn {
+				if security.BlessingPattern(p).MatchedBy(names...) {
+					check.ExcludedBy = append(check.ExcludedBy, p)
+				}
+			}
+			if !check.Granted && len(check.ExcludedBy) == 0 {
+				check.Missing = closestPattern(acl.In, names)
+			}
+			obj.ACLs = append(obj.ACLs, check)
+		}
+		exp.Objects = append(exp.Objects, obj)
+	}
+	if accessErr == nil {
+		return exp, nil
+	}
+	exp.Error = accessErr.Error()
+
+	available, err := c.ListFilters(ctx, req.Dataset, req.Version)
+	if err != nil {
+		exp.FilterListError = err.Error()
+		return exp, accessErr
+	}
+	requested := map[string]bool{}
+	for _, f := range req.Filters {
+		requested[f] = true
+	}
+	for _, f := range available {
+		if requested[f] {
+			continue
+		}
+		subset := req
+		subset.Filters = append(append([]string{}, req.Filters...), f)
+		if c.CheckAccess(ctx, subset) == nil {
+			exp.AllowedFilters = append(exp.AllowedFilters, f)
+		}
+	}
+	return exp, accessErr
+}
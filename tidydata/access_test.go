@@ -0,0 +1,27 @@
+package tidydata
+
+import (
+	"strings"
+	"testing"
+
+	"v.io/v23/security"
+)
+
+func TestClosestPattern(t *testing.T) {
+	in := []security.BlessingPattern{"dev.v.io:u:bob", "dev.v.io:g:tidy:readers", "dev.v.io:u:alice:ci"}
+	tests := map[string]string{
+		"dev.v.io:u:alice":                 "dev.v.io:u:alice:ci",
+		"dev.v.io:u:carol":                 "dev.v.io:u:bob",
+		"dev.v.io:g:tidy:writers":          "dev.v.io:g:tidy:readers",
+		"other.io:u:alice":                 "dev.v.io:u:bob",
+		"dev.v.io:u:carol,dev.v.io:g:tidy": "dev.v.io:g:tidy:readers",
+	}
+	for names, want := range tests {
+		if got := closestPattern(in, strings.Split(names, ",")); got != want {
+			t.Errorf("closestPattern(%s) = %s, want %s", names, got, want)
+		}
+	}
+	if got := closestPattern(nil, []string{"dev.v.io:u:alice"}); got != "" {
+		t.Errorf("closestPattern of an empty ACL = %q, want none", got)
+	}
+}
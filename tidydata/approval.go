@@ -0,0 +1,256 @@
+package tidydata
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+)
+
+// Statuses of publish requests.
+const (
+	RequestPending  = "pending"
+	RequestApproved = "approved"
+)
+
+// PublishRequest is a request to change the publish state of a version,
+// which is only applied once approved by a different identity.
+type PublishRequest struct {
+	ID      string `json:"id"`
+	Dataset string `json:"dataset"`
+	Version string `json:"version"`
+	// State is the requested publish state.
+	State string `json:"state"`
+	// PriorState is the state of the version when the request was made.
+	// The request can't be approved once the version leaves it.
+	PriorState string    `json:"prior_state"`
+	Note       string    `json:"note,omitempty"`
+	Requester  []string  `json:"requester"`
+	Requested  time.Time `json:"requested"`
+	Status     string    `json:"status"`
+	Approver   []string  `json:"approver,omitempty"`
+	Approved   time.Time `json:"approved"`
+}
+
+// ErrApprovalRequired is returned, wrapped, when a client is asked to move
+// a version to the published state or above other than by approving a
+// publish request.
+var ErrApprovalRequired = errors.New("publishing requires approval")
+
+// ApprovalStore keeps publish requests as JSON files in a directory, which
+// requesters and approvers must share.
+//
+// The tidy server has no notion of approval, so it is enforced by the
+// clients: every client refuses to move a version to the published state
+// or above, including through Undo, except by approving a request, and
+// refuses outright if it has no store. There is deliberately no setting to
+// turn this off.
+type ApprovalStore struct {
+	Dir string
+}
+
+func (s *ApprovalStore) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
+
+// Load returns the request with the given ID.
+func (s *ApprovalStore) Load(id string) (*PublishRequest, error) {
+	r := &PublishRequest{}
+	if err := readJSONFile(s.path(id), r); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no publish request %s in %s", id, s.Dir)
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// claim takes the lock on the request with the given ID, so that only one
+// approver can act on it at a time, and returns a function that releases
+// it. The lock is a file created exclusively next to the request; if an
+// approver is killed while holding it, it must be removed by hand.
+func (s *ApprovalStore) claim(id string) (func(), error) {
+	lock := s.path(id) + ".lock"
+	f, err := os.OpenFile(lock, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if os.IsExist(err) {
+		return nil, fmt.Errorf("publish request %s is being approved by another identity (lock %s)", id, lock)
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return func() { os.Remove(lock) }, nil
+}
+
+// Save writes a request to the store.
+func (s *ApprovalStore) Save(r *PublishRequest) error {
+	if err := os.MkdirAll(s.Dir, 0777); err != nil {
+		return err
+	}
+	return writeJSONFile(s.path(r.ID), r)
+}
+
+// List returns the requests in the store with the given status, or all
+// requests if status is empty, oldest first.
+func (s *ApprovalStore) List(status string) ([]*PublishRequest, error) {
+	infos, err := ioutil.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var requests []*PublishRequest
+	for _, info := range infos {
+		if !strings.HasSuffix(info.Name(), ".json") {
+			continue
+		}
+		r, err := s.Load(strings.TrimSuffix(info.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if status == "" || r.Status == status {
+			requests = append(requests, r)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].Requested.Before(requests[j].Requested) })
+	return requests, nil
+}
+
+// SetApprovalStore sets the store of publish requests through which the
+// client approves publish state changes. Without one, the client refuses
+// them.
+func (c *Client) SetApprovalStore(s *ApprovalStore) {
+	c.approvals = s
+}
+
+// checkApproval returns an error wrapping ErrApprovalRequired if the
+// operation r moves a version to the published state or above, other than
+// to failed, without applying an approved publish request.
+func (c *Client) checkApproval(r AuditRecord) error {
+	if r.Operation != OpUpdateState || r.Approves != "" {
+		return nil
+	}
+	state, err := vdl.StateFromString(r.Args[2])
+	if err != nil {
+		return err
+	}
+	if state < vdl.StatePublished || IsFailedState(state) {
+		return nil
+	}
+	if c.approvals == nil {
+		return fmt.Errorf("%s %s -> %s: %w, and the client has no approval store to request it in", r.Dataset, r.Args[1], r.Args[2], ErrApprovalRequired)
+	}
+	return fmt.Errorf("%s %s -> %s: %w by another identity: use version request-publish", r.Dataset, r.Args[1], r.Args[2], ErrApprovalRequired)
+}
+
+// noPrior is the prior value of operations that change no server state.
+func noPrior() (*PriorValue, error) {
+	return nil, nil
+}
+
+// RequestPublish records a pending request to move a version to state in
+// the client's approval store, on behalf of the caller, recording it in the
+// client's audit log.
+func (c *Client) RequestPublish(ctx *context.T, dataset, version string, state vdl.State, note string) (*PublishRequest, error) {
+	if c.approvals == nil {
+		return nil, fmt.Errorf("the client has no approval store")
+	}
+	requester := callerBlessings(ctx)
+	if len(requester) == 0 {
+		return nil, fmt.Errorf("the caller has no blessings to record as the requester")
+	}
+	snap, err := c.Snapshot(ctx, dataset)
+	if err != nil {
+		return nil, err
+	}
+	prior, ok := snap.States[version]
+	if !ok {
+		return nil, fmt.Errorf("%s has no version %s", dataset, version)
+	}
+	r := &PublishRequest{
+		ID:         newOperationID(),
+		Dataset:    dataset,
+		Version:    version,
+		State:      state.String(),
+		PriorState: prior.String(),
+		Note:       note,
+		Requester:  requester,
+		Requested:  time.Now().UTC(),
+		Status:     RequestPending,
+	}
+	audit := AuditRecord{Operation: OpRequestPublish, Dataset: dataset, Args: []string{dataset, version, r.State, r.ID}}
+	return r, c.auditedRecord(ctx, audit, noPrior, func() error { return c.approvals.Save(r) })
+}
+
+// Approve applies the pending request with the given ID in the client's
+// approval store on behalf of the caller, who must share no blessing with
+// the requester. It refuses if the version's state has changed since the
+// request was made. The state change is recorded in the client's audit log
+// with the ID of the request it approves.
+//
+// The request is locked while it is approved, and marked approved before
+// the state change is applied, so that it is applied at most once even if
+// approvers race; if the change fails, the request is returned to pending.
+func (c *Client) Approve(ctx *context.T, id string) (*PublishRequest, error) {
+	if c.approvals == nil {
+		return nil, fmt.Errorf("the client has no approval store")
+	}
+	s := c.approvals
+	approver := callerBlessings(ctx)
+	if len(approver) == 0 {
+		return nil, fmt.Errorf("the caller has no blessings to record as the approver")
+	}
+	release, err := s.claim(id)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	r, err := s.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != RequestPending {
+		return nil, fmt.Errorf("publish request %s is %s", id, r.Status)
+	}
+	for _, a := range approver {
+		if contains(r.Requester, a) {
+			return nil, fmt.Errorf("publish request %s must be approved by an identity other than its requester %s", id, a)
+		}
+	}
+	state, err := vdl.StateFromString(r.State)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := c.Snapshot(ctx, r.Dataset)
+	if err != nil {
+		return nil, err
+	}
+	if current := snap.States[r.Version].String(); current != r.PriorState {
+		return nil, fmt.Errorf("refusing to approve publish request %s: %s %s has changed from %s to %s since it was requested", id, r.Dataset, r.Version, r.PriorState, current)
+	}
+	pending := *r
+	r.Status = RequestApproved
+	r.Approver = approver
+	r.Approved = time.Now().UTC()
+	if err := s.Save(r); err != nil {
+		return nil, err
+	}
+	update := AuditRecord{Operation: OpUpdateState, Dataset: r.Dataset, Args: []string{r.Dataset, r.Version, r.State}, Approves: id}
+	if err := c.adminRecord(ctx, update, func() error {
+		return c.tidy.UpdateVersionState(r.Dataset, r.Version, state)
+	}); err != nil {
+		if serr := s.Save(&pending); serr != nil {
+			return nil, fmt.Errorf("%v; publish request %s is marked approved but wasn't applied, and couldn't be returned to pending: %v", err, id, serr)
+		}
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,64 @@
+package tidydata
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCheckApproval(t *testing.T) {
+	update := func(state string) AuditRecord {
+		return AuditRecord{Operation: OpUpdateState, Dataset: "ccga", Args: []string{"ccga", "v3", state}}
+	}
+	approved := update("published")
+	approved.Approves = "req1"
+	alias := AuditRecord{Operation: OpAddAlias, Dataset: "ccga", Args: []string{"ccga", "v3", "latest"}}
+
+	// Publishing needs approval whether or not the client has a store;
+	// without one it can't be requested at all.
+	for _, store := range []*ApprovalStore{nil, {Dir: "unused"}} {
+		c := NewClientFrom("", nil)
+		c.SetApprovalStore(store)
+		if err := c.checkApproval(update("published")); !errors.Is(err, ErrApprovalRequired) {
+			t.Errorf("store %v: publishing without approval = %v, want ErrApprovalRequired", store, err)
+		}
+		for _, r := range []AuditRecord{update("tested"), update("failed"), approved, alias} {
+			if err := c.checkApproval(r); err != nil {
+				t.Errorf("store %v: %s %q = %v, want no approval needed", store, r.Operation, r.Args, err)
+			}
+		}
+	}
+}
+
+func TestApprovalStoreClaim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "approval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := &ApprovalStore{Dir: dir}
+	r := &PublishRequest{ID: "req1", Dataset: "ccga", Version: "v3", State: "published", Requested: time.Now(), Status: RequestPending}
+	if err := s.Save(r); err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := s.claim("req1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.claim("req1"); err == nil {
+		t.Fatal("a second approver claimed a request that was already claimed")
+	}
+	// The lock doesn't show up as a request.
+	if requests, err := s.List(""); err != nil || len(requests) != 1 {
+		t.Errorf("List while claimed = %v, %v, want just req1", requests, err)
+	}
+	release()
+	release, err = s.claim("req1")
+	if err != nil {
+		t.Fatalf("claim after release: %v", err)
+	}
+	release()
+}
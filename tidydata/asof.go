@@ -0,0 +1,91 @@
+package tidydata
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+)
+
+// The tidy server keeps no history of aliases or publish states, so
+// historical resolution relies on what this client has recorded: the
+// version history, which records aliases and states whenever versions are
+// listed or resolved, and the fetch log, which records what each fetched
+// alias resolved to.
+
+// ResolveVersionAsOf returns the version that version, which may be an
+// alias, resolved to at asOf, according to the most recent observation of
+// the alias at or before asOf in the version history or the fetch log. A
+// version that the server resolves to itself is returned unchanged. It
+// returns an error if the local records do not say what an alias pointed
+// to at asOf.
+func (c *Client) ResolveVersionAsOf(ctx *context.T, dataset, version string, asOf time.Time) (string, error) {
+	var (
+		last     AliasChange
+		observed bool
+	)
+	if c.history != nil {
+		last, observed = c.history.AliasAt(dataset, version, asOf)
+	}
+	if c.fetchLog != nil {
+		records, err := c.fetchLog.Records()
+		if err != nil {
+			return "", err
+		}
+		for _, r := range records {
+			if r.Error == "" && !r.Offline && r.Dataset == dataset && r.Requested == version && !r.Time.After(asOf) && (!observed || r.Time.After(last.Since)) {
+				last, observed = AliasChange{Version: r.Version, Since: r.Time}, true
+			}
+		}
+	}
+	if observed {
+		if last.Version == "" {
+			return "", fmt.Errorf("alias %s of %s had been removed at %s", version, dataset, asOf.Format(time.RFC3339))
+		}
+		return last.Version, nil
+	}
+	current, err := c.ResolveVersion(ctx, dataset, version)
+	if err != nil {
+		return "", err
+	}
+	if current == version {
+		return version, nil
+	}
+	return "", fmt.Errorf("no local record of what %s of %s pointed to at %s", version, dataset, asOf.Format(time.RFC3339))
+}
+
+// latestVersionAsOf implements LatestVersion for a request with an AsOf
+// time, using the states recorded in the version history.
+func (c *Client) latestVersionAsOf(ctx *context.T, req LatestRequest) (string, error) {
+	if c.history == nil {
+		return "", fmt.Errorf("the client keeps no version history")
+	}
+	var versions []string
+	for v := range c.history.Datasets[req.Dataset] {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return NaturalLess(versions[i], versions[j]) })
+	for i := len(versions) - 1; i >= 0; i-- {
+		s, ok := c.history.Record(req.Dataset, versions[i]).StateAt(req.AsOf)
+		if !ok {
+			continue
+		}
+		state, err := vdl.StateFromString(s)
+		if err != nil || state < req.MinState || IsFailedState(state) {
+			continue
+		}
+		if req.Tableset != "" {
+			tablesets, err := c.ListTablesets(ctx, req.Dataset, versions[i])
+			if err != nil {
+				return "", err
+			}
+			if !contains(tablesets, req.Tableset) {
+				continue
+			}
+		}
+		return versions[i], nil
+	}
+	return "", fmt.Errorf("%s: %w at or above %s as of %s in the local version history", req.Dataset, ErrNoVersion, req.MinState.String(), req.AsOf.Format(time.RFC3339))
+}
@@ -0,0 +1,248 @@
+package tidydata
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"v.io/v23/context"
+)
+
+// Administrative operations recorded in the audit log, named after the
+// version subcommands that perform them.
+const (
+	OpAddVersion        = "add"
+	OpUpdateState       = "update"
+	OpUpdateDescription = "update-description"
+	OpAddAlias          = "add-alias"
+	OpUpdateAlias       = "update-alias"
+	OpRemoveAlias       = "remove-alias"
+	OpRequestPublish    = "request-publish"
+)
+
+// PriorValue is the server state that an administrative operation changed,
+// captured just before the operation.
+type PriorValue struct {
+	// State is the publish state of the version, or empty if it did not
+	// exist.
+	State string `json:"state,omitempty"`
+	// Description is the description of the version.
+	Description string `json:"description,omitempty"`
+	// Aliases maps each alias affected by the operation to the version it
+	// pointed to, or to an empty string if it did not exist.
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// AuditRecord records an administrative operation.
+type AuditRecord struct {
+	// ID identifies the operation.
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Dataset   string    `json:"dataset"`
+	Args      []string  `json:"args"`
+	// Identity lists the blessing names of the caller.
+	Identity []string `json:"identity"`
+	// Endpoint is the vanadium address of the server.
+	Endpoint string      `json:"endpoint"`
+	Prior    *PriorValue `json:"prior,omitempty"`
+	// PriorError records why the prior value could not be captured.
+	PriorError string `json:"prior_error,omitempty"`
+	// Error is empty if the operation succeeded.
+	Error string `json:"error,omitempty"`
+	// Undoes is the ID of the operation this operation reverted, if any.
+	Undoes string `json:"undoes,omitempty"`
+	// Reason is the reason given for overriding the protection of the
+	// aliases the operation changed, if any.
+	Reason string `json:"reason,omitempty"`
+	// Approves is the ID of the publish request whose approval the
+	// operation applied, if any.
+	Approves string `json:"approves,omitempty"`
+}
+
+// OK reports whether the operation succeeded.
+func (r AuditRecord) OK() bool {
+	return r.Error == ""
+}
+
+// AuditLog is an append-only log of administrative operations, stored as a
+// file of JSON records, one per line.
+type AuditLog struct {
+	Path string
+}
+
+// Append adds a record to the log.
+func (l *AuditLog) Append(r AuditRecord) error {
+	return appendJSONLine(l.Path, r)
+}
+
+// AuditQuery selects audit records. Zero fields match every record.
+type AuditQuery struct {
+	Dataset string
+	// Actor matches records any of whose identity blessings contains it.
+	Actor string
+	// Since and Until restrict records to those in [Since, Until).
+	Since, Until time.Time
+}
+
+// Matches reports whether r is selected by q.
+func (q AuditQuery) Matches(r AuditRecord) bool {
+	if q.Dataset != "" && r.Dataset != q.Dataset {
+		return false
+	}
+	if (!q.Since.IsZero() && r.Time.Before(q.Since)) || (!q.Until.IsZero() && !r.Time.Before(q.Until)) {
+		return false
+	}
+	if q.Actor == "" {
+		return true
+	}
+	for _, b := range r.Identity {
+		if strings.Contains(b, q.Actor) {
+			return true
+		}
+	}
+	return false
+}
+
+// Query returns the records selected by q in the order they were appended.
+func (l *AuditLog) Query(q AuditQuery) ([]AuditRecord, error) {
+	var records []AuditRecord
+	err := readJSONLines(l.Path, func(line []byte) error {
+		var r AuditRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+		if q.Matches(r) {
+			records = append(records, r)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// SetAuditLog makes the client record every administrative operation it
+// performs in l. A nil l disables recording.
+func (c *Client) SetAuditLog(l *AuditLog) {
+	c.audit = l
+}
+
+func newOperationID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// admin performs the administrative operation op, whose version
+// subcommand arguments are args, with do, recording it in the client's
+// audit log, if it has one, along with the value it changes.
+// Operations that change protected aliases fail with a
+// ProtectedAliasError unless the client has an override, and publish state
+// changes that need approval fail with ErrApprovalRequired.
+func (c *Client) admin(ctx *context.T, op string, args []string, do func() error) error {
+	return c.adminRecord(ctx, AuditRecord{Operation: op, Dataset: args[0], Args: args}, do)
+}
+
+// adminRecord is admin for an operation described by r, which need only
+// have its Operation, Dataset, Args and Approves set.
+func (c *Client) adminRecord(ctx *context.T, r AuditRecord, do func() error) error {
+	if err := c.checkProtection(ctx, &r); err != nil {
+		return err
+	}
+	if err := c.checkApproval(r); err != nil {
+		return err
+	}
+	return c.auditedRecord(ctx, r, c.priorFor(ctx, r.Operation, r.Args), do)
+}
+
+// checkProtection returns a ProtectedAliasError if the operation r changes
+// protected aliases and the client has no override, and otherwise records
+// the override's reason in r.
+func (c *Client) checkProtection(ctx *context.T, r *AuditRecord) error {
+	impacts, err := c.AliasImpact(ctx, r.Operation, r.Args)
+	if err != nil || len(impacts) == 0 {
+		return err
+	}
+	if c.override == "" {
+		return &ProtectedAliasError{Impacts: impacts}
+	}
+	r.Reason = c.override
+	return nil
+}
+
+// auditedRecord performs an operation with do, recording it in the
+// client's audit log, if it has one, as r, which need only have its
+// Operation, Dataset, Args and Undoes set, along with the prior value
+// returned by prior. A failure to record an operation that succeeded is
+// reported with warnf rather than returned, since the operation has already
+// taken effect on the server.
+func (c *Client) auditedRecord(ctx *context.T, r AuditRecord, prior func() (*PriorValue, error), do func() error) error {
+	if c.audit == nil {
+		return do()
+	}
+	r.ID = newOperationID()
+	r.Time = time.Now().UTC()
+	r.Identity = callerBlessings(ctx)
+	r.Endpoint = c.address
+	var err error
+	if r.Prior, err = prior(); err != nil {
+		r.PriorError = err.Error()
+	}
+	err = do()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if aerr := c.audit.Append(r); aerr != nil {
+		c.warnf("couldn't record %s of %s in %s: %v", r.Operation, r.Dataset, c.audit.Path, aerr)
+	}
+	return err
+}
+
+// priorVersion returns a function capturing the state of a version and the
+// versions of aliases.
+func (c *Client) priorVersion(ctx *context.T, dataset, version string, aliases ...string) func() (*PriorValue, error) {
+	return func() (*PriorValue, error) {
+		snap, err := c.Snapshot(ctx, dataset)
+		if err != nil {
+			return nil, err
+		}
+		prior := &PriorValue{}
+		if state, ok := snap.States[version]; ok && version != "" {
+			prior.State = state.String()
+		}
+		if len(aliases) > 0 {
+			prior.Aliases = map[string]string{}
+			for _, a := range aliases {
+				prior.Aliases[a] = snap.Aliases[a]
+			}
+		}
+		return prior, nil
+	}
+}
+
+// priorFor returns a function capturing the value that the operation op,
+// with the arguments of its version subcommand, changes.
+func (c *Client) priorFor(ctx *context.T, op string, args []string) func() (*PriorValue, error) {
+	dataset := args[0]
+	switch op {
+	case OpAddVersion, OpUpdateState:
+		return c.priorVersion(ctx, dataset, args[1])
+	case OpUpdateDescription:
+		return func() (*PriorValue, error) {
+			desc, err := c.VersionDescription(ctx, dataset, args[1])
+			if err != nil {
+				return nil, err
+			}
+			return &PriorValue{Description: desc}, nil
+		}
+	case OpAddAlias:
+		return c.priorVersion(ctx, dataset, "", args[2])
+	case OpUpdateAlias:
+		return c.priorVersion(ctx, dataset, "", args[1], args[2])
+	}
+	return c.priorVersion(ctx, dataset, "", args[1])
+}
@@ -0,0 +1,36 @@
+package tidydata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditQueryMatches(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	r := AuditRecord{Time: t0, Dataset: "ccga", Identity: []string{"dev.v.io:u:alice@grail.com"}}
+	matching := []AuditQuery{
+		{},
+		{Dataset: "ccga"},
+		{Actor: "alice"},
+		{Since: t0, Until: t0.Add(time.Second)},
+		{Dataset: "ccga", Actor: "alice@grail.com", Since: t0},
+	}
+	for _, q := range matching {
+		if !q.Matches(r) {
+			t.Errorf("%+v doesn't match %+v", q, r)
+		}
+	}
+	// Until is exclusive.
+	excluding := []AuditQuery{
+		{Dataset: "pcr"},
+		{Actor: "bob"},
+		{Since: t0.Add(time.Second)},
+		{Until: t0},
+		{Dataset: "ccga", Actor: "bob"},
+	}
+	for _, q := range excluding {
+		if q.Matches(r) {
+			t.Errorf("%+v matches %+v", q, r)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package tidydata
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+)
+
+// CatalogEntry is a single searchable object in a catalog.
+type CatalogEntry struct {
+	Kind        string `json:"kind"`
+	Dataset     string `json:"dataset"`
+	Version     string `json:"version,omitempty"`
+	Tableset    string `json:"tableset,omitempty"`
+	Table       string `json:"table,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Location returns the dotted path identifying the entry.
+func (e CatalogEntry) Location() string {
+	parts := []string{e.Dataset}
+	for _, p := range []string{e.Version, e.Tableset, e.Table} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if e.Kind != "dataset" && e.Kind != "version" {
+		parts = append(parts, e.Name)
+	}
+	return strings.Join(parts, "/")
+}
+
+// CatalogPosting records that a token occurs in the name or description of
+// the entry with the given index.
+type CatalogPosting struct {
+	Entry  int  `json:"e"`
+	InName bool `json:"n,omitempty"`
+}
+
+// Catalog is a local metadata mirror along with an inverted index from
+// lowercase tokens to the entries containing them.
+type Catalog struct {
+	Synced  time.Time                   `json:"synced"`
+	Address string                      `json:"address"`
+	Entries []CatalogEntry              `json:"entries"`
+	Index   map[string][]CatalogPosting `json:"index"`
+}
+
+// tokenize splits s into lowercase alphanumeric tokens.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// add appends an entry to the catalog and indexes its name and description.
+func (cat *Catalog) add(e CatalogEntry) {
+	idx := len(cat.Entries)
+	cat.Entries = append(cat.Entries, e)
+	seen := map[string]bool{}
+	for _, t := range tokenize(e.Name) {
+		if !seen[t] {
+			seen[t] = true
+			cat.Index[t] = append(cat.Index[t], CatalogPosting{Entry: idx, InName: true})
+		}
+	}
+	for _, t := range tokenize(e.Description) {
+		if !seen[t] {
+			seen[t] = true
+			cat.Index[t] = append(cat.Index[t], CatalogPosting{Entry: idx})
+		}
+	}
+}
+
+// crawlVersion adds a version and all of its tablesets, tables, columns and
+// filters to the catalog.
+func (cat *Catalog) crawlVersion(ctx *context.T, c *Client, dataset, version, description string) error {
+	site, err := c.BuildDocsSite(ctx, dataset, version)
+	if err != nil {
+		return err
+	}
+	if description == "" {
+		description = site.Description
+	}
+	cat.add(CatalogEntry{Kind: "version", Dataset: dataset, Version: version, Name: version, Description: description})
+	for _, ts := range site.Tablesets {
+		cat.add(CatalogEntry{Kind: "tableset", Dataset: dataset, Version: version, Name: ts.Name, Description: ts.Description})
+		for _, t := range ts.Tables {
+			cat.add(CatalogEntry{Kind: "table", Dataset: dataset, Version: version, Tableset: ts.Name, Name: t.Name})
+			for _, col := range t.Columns {
+				cat.add(CatalogEntry{Kind: "column", Dataset: dataset, Version: version, Tableset: ts.Name, Table: t.Name, Name: col.Name, Description: col.Description})
+			}
+		}
+	}
+	for _, f := range site.Filters {
+		cat.add(CatalogEntry{Kind: "filter", Dataset: dataset, Version: version, Name: f.Name, Description: f.Description + " " + f.QueryString})
+	}
+	return nil
+}
+
+// CatalogSyncRequest selects what to crawl into a catalog.
+type CatalogSyncRequest struct {
+	// Datasets to crawl; all datasets if empty.
+	Datasets []string
+	// MinState is the minimum publish state of crawled versions.
+	MinState vdl.State
+	// Log, if set, receives progress messages and the reasons datasets
+	// and versions were skipped.
+	Log io.Writer
+}
+
+// SyncCatalog crawls the server and returns a fresh catalog. Datasets and
+// versions that cannot be crawled, for example for lack of access, are
+// skipped.
+func (c *Client) SyncCatalog(ctx *context.T, req CatalogSyncRequest) (*Catalog, error) {
+	cat := &Catalog{Synced: time.Now(), Address: c.address, Index: map[string][]CatalogPosting{}}
+	logf := func(format string, args ...interface{}) {
+		if req.Log != nil {
+			fmt.Fprintf(req.Log, format, args...)
+		}
+	}
+	datasets := req.Datasets
+	if len(datasets) == 0 {
+		var err error
+		if datasets, err = c.ListDatasets(ctx); err != nil {
+			return nil, err
+		}
+	}
+	for _, dataset := range datasets {
+		description, err := c.DescribeDataset(ctx, dataset)
+		if err != nil {
+			logf("skipping dataset %s: %v\n", dataset, err)
+			continue
+		}
+		cat.add(CatalogEntry{Kind: "dataset", Dataset: dataset, Name: dataset, Description: description})
+		descriptions := map[string]string{}
+		if aliased, err := c.ListVersions(ctx, VersionsRequest{Dataset: dataset, WithAlias: true}); err == nil {
+			for _, v := range aliased {
+				if v.Description != "" {
+					descriptions[v.Version] = v.Description
+				}
+			}
+		}
+		versions, err := c.ListVersions(ctx, VersionsRequest{Dataset: dataset, MinState: req.MinState})
+		if err != nil {
+			logf("skipping dataset %s: %v\n", dataset, err)
+			continue
+		}
+		for _, v := range versions {
+			logf("crawling %s %s\n", dataset, v.Version)
+			if err := cat.crawlVersion(ctx, c, dataset, v.Version, descriptions[v.Version]); err != nil {
+				logf("skipping version %s %s: %v\n", dataset, v.Version, err)
+			}
+		}
+	}
+	return cat, nil
+}
+
+// Merge returns a catalog holding the entries of fresh along with those of
+// cat for the datasets fresh does not include, so that a sync of some
+// datasets updates them without dropping the others.
+func (cat *Catalog) Merge(fresh *Catalog) *Catalog {
+	synced := map[string]bool{}
+	for _, e := range fresh.Entries {
+		synced[e.Dataset] = true
+	}
+	merged := &Catalog{Synced: fresh.Synced, Address: fresh.Address, Index: map[string][]CatalogPosting{}}
+	for _, e := range cat.Entries {
+		if !synced[e.Dataset] {
+			merged.add(e)
+		}
+	}
+	for _, e := range fresh.Entries {
+		merged.add(e)
+	}
+	return merged
+}
+
+// LoadCatalog reads a catalog saved by Save. It returns an error
+// satisfying os.IsNotExist if there is no catalog at path.
+func LoadCatalog(path string) (*Catalog, error) {
+	cat := &Catalog{}
+	if err := readJSONFile(path, cat); err != nil {
+		return nil, err
+	}
+	return cat, nil
+}
+
+// Save writes the catalog to path.
+func (cat *Catalog) Save(path string) error {
+	return writeJSONFile(path, cat)
+}
+
+// CatalogMatch is a Search result.
+type CatalogMatch struct {
+	CatalogEntry
+	Score float64 `json:"score"`
+}
+
+// SearchRequest is a catalog query.
+type SearchRequest struct {
+	Terms []string
+	// Dataset, Version and Kind, if set, restrict the matches.
+	Dataset string
+	Version string
+	Kind    string
+}
+
+// Search returns the entries matching every term of req, ranked by a
+// tf-idf style score in which matches in names count more than matches in
+// descriptions. A term matches a token if it is a prefix of the token.
+func (cat *Catalog) Search(req SearchRequest) []CatalogMatch {
+	terms, dataset, version, kind := req.Terms, req.Dataset, req.Version, req.Kind
+	var queryTokens []string
+	for _, t := range terms {
+		queryTokens = append(queryTokens, tokenize(t)...)
+	}
+	if len(queryTokens) == 0 {
+		return nil
+	}
+	n := float64(len(cat.Entries))
+	scores := map[int]float64{}
+	for i, qt := range queryTokens {
+		termScores := map[int]float64{}
+		for token, postings := range cat.Index {
+			if !strings.HasPrefix(token, qt) {
+				continue
+			}
+			idf := math.Log(1 + n/float64(len(postings)))
+			weight := idf
+			if token == qt {
+				weight *= 2
+			}
+			for _, p := range postings {
+				w := weight
+				if p.InName {
+					w *= 3
+				}
+				if w > termScores[p.Entry] {
+					termScores[p.Entry] = w
+				}
+			}
+		}
+		if i == 0 {
+			scores = termScores
+			continue
+		}
+		for e := range scores {
+			if s, ok := termScores[e]; ok {
+				scores[e] += s
+			} else {
+				delete(scores, e)
+			}
+		}
+	}
+	query := strings.ToLower(strings.Join(terms, " "))
+	var matches []CatalogMatch
+	for idx, score := range scores {
+		e := cat.Entries[idx]
+		if (dataset != "" && e.Dataset != dataset) || (version != "" && e.Version != version) || (kind != "" && e.Kind != kind) {
+			continue
+		}
+		if strings.ToLower(e.Name) == query {
+			score *= 2
+		}
+		matches = append(matches, CatalogMatch{e, score})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Location() < matches[j].Location()
+	})
+	return matches
+}
@@ -0,0 +1,91 @@
+package tidydata
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testEntries = []CatalogEntry{
+	{Kind: "dataset", Dataset: "ccga", Name: "ccga", Description: "Circulating cell-free genome atlas"},
+	{Kind: "version", Dataset: "ccga", Version: "v3", Name: "v3", Description: "Adds methylation calls"},
+	{Kind: "tableset", Dataset: "ccga", Version: "v3", Name: "clinical"},
+	{Kind: "table", Dataset: "ccga", Version: "v3", Tableset: "clinical", Name: "patients"},
+	{Kind: "column", Dataset: "ccga", Version: "v3", Tableset: "clinical", Table: "patients", Name: "patient_id", Description: "Unique patient identifier"},
+	{Kind: "column", Dataset: "pcr", Version: "v1", Tableset: "results", Table: "assays", Name: "methylation_score"},
+}
+
+func TestCatalogEntryLocation(t *testing.T) {
+	want := []string{
+		"ccga",
+		"ccga/v3",
+		"ccga/v3/clinical",
+		"ccga/v3/clinical/patients",
+		"ccga/v3/clinical/patients/patient_id",
+		"pcr/v1/results/assays/methylation_score",
+	}
+	for i, e := range testEntries {
+		if got := e.Location(); got != want[i] {
+			t.Errorf("%s %s: Location() = %s, want %s", e.Kind, e.Name, got, want[i])
+		}
+	}
+}
+
+func TestCatalogSearch(t *testing.T) {
+	cat := &Catalog{Index: map[string][]CatalogPosting{}}
+	for _, e := range testEntries {
+		cat.add(e)
+	}
+	tests := []struct {
+		req  SearchRequest
+		want []string
+	}{
+		{SearchRequest{}, nil},
+		{SearchRequest{Terms: []string{"nothing"}}, nil},
+		// Matches in names rank above matches in descriptions.
+		{SearchRequest{Terms: []string{"methylation"}}, []string{"pcr/v1/results/assays/methylation_score", "ccga/v3"}},
+		// Terms match token prefixes, whole tokens rank higher and every
+		// term must match.
+		{SearchRequest{Terms: []string{"patient"}}, []string{"ccga/v3/clinical/patients/patient_id", "ccga/v3/clinical/patients"}},
+		{SearchRequest{Terms: []string{"patient id"}}, []string{"ccga/v3/clinical/patients/patient_id"}},
+		{SearchRequest{Terms: []string{"patient", "unique"}}, []string{"ccga/v3/clinical/patients/patient_id"}},
+		{SearchRequest{Terms: []string{"methylation"}, Dataset: "ccga"}, []string{"ccga/v3"}},
+		{SearchRequest{Terms: []string{"patient"}, Kind: "column"}, []string{"ccga/v3/clinical/patients/patient_id"}},
+		{SearchRequest{Terms: []string{"patient"}, Version: "v1"}, nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, m := range cat.Search(test.req) {
+			got = append(got, m.Location())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Search(%+v) = %q, want %q", test.req, got, test.want)
+		}
+	}
+}
+
+// TestCatalogMerge checks that a partial sync replaces the synced datasets
+// and keeps the others.
+func TestCatalogMerge(t *testing.T) {
+	old := &Catalog{Index: map[string][]CatalogPosting{}}
+	old.add(CatalogEntry{Kind: "dataset", Dataset: "ccga", Name: "ccga"})
+	old.add(CatalogEntry{Kind: "version", Dataset: "ccga", Version: "v2", Name: "v2"})
+	old.add(CatalogEntry{Kind: "dataset", Dataset: "pcr", Name: "pcr"})
+	fresh := &Catalog{Index: map[string][]CatalogPosting{}}
+	fresh.add(CatalogEntry{Kind: "dataset", Dataset: "ccga", Name: "ccga"})
+	fresh.add(CatalogEntry{Kind: "version", Dataset: "ccga", Version: "v3", Name: "v3"})
+
+	merged := old.Merge(fresh)
+	var locations []string
+	for _, e := range merged.Entries {
+		locations = append(locations, e.Location())
+	}
+	if want := []string{"pcr", "ccga", "ccga/v3"}; !reflect.DeepEqual(locations, want) {
+		t.Errorf("merged entries are %q, want %q", locations, want)
+	}
+	if m := merged.Search(SearchRequest{Terms: []string{"v2"}}); len(m) != 0 {
+		t.Errorf("the replaced version v2 is still indexed: %+v", m)
+	}
+	if m := merged.Search(SearchRequest{Terms: []string{"pcr"}}); len(m) != 1 {
+		t.Errorf("the unsynced dataset pcr is not indexed: %+v", m)
+	}
+}
@@ -0,0 +1,878 @@
+// Package tidydata implements the operations behind the tidydata-client
+// command as a library. Each operation takes a context and a typed request
+// and returns typed results rather than printing them, so that Go programs
+// can query and administer tidy datasets without shelling out to the
+// command.
+//
+// Besides the tidy and vanadium packages, the package depends on
+// modernc.org/sqlite, a pure-Go SQLite driver, to read fetched tidysets.
+// It must be provided alongside them when building the client.
+package tidydata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	tidy "grail.com/tidy/vanadium/client"
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+)
+
+// Client performs operations against a tidy server.
+type Client struct {
+	address   string
+	tidy      tidy.Client
+	verifier  *Verifier
+	history   *VersionHistory
+	fetchLog  *FetchLog
+	audit     *AuditLog
+	policy    *AliasPolicy
+	override  string
+	approvals *ApprovalStore
+	cache     *MetadataCache
+	progress  *Progress
+	warn      func(error)
+}
+
+// NewClient returns a Client that communicates with the tidy server at the
+// given vanadium address.
+func NewClient(ctx *context.T, address string) *Client {
+	return &Client{address: address, tidy: tidy.NewTidyClient(ctx, address)}
+}
+
+// NewClientFrom returns a Client that uses an existing tidy.Client, which
+// communicates with the server at address.
+func NewClientFrom(address string, c tidy.Client) *Client {
+	return &Client{address: address, tidy: c}
+}
+
+// Address returns the vanadium address of the server.
+func (c *Client) Address() string {
+	return c.address
+}
+
+// Tidy returns the underlying tidy.Client.
+func (c *Client) Tidy() tidy.Client {
+	return c.tidy
+}
+
+// SetWarn makes the client report to f the failures of its local
+// bookkeeping, such as recording a fetch in the fetch log or caching a
+// metadata response, which do not fail the operations they accompany. They
+// are written to standard error by default.
+func (c *Client) SetWarn(f func(error)) {
+	c.warn = f
+}
+
+// warnf reports a failure of the client's local bookkeeping.
+func (c *Client) warnf(format string, args ...interface{}) {
+	err := fmt.Errorf(format, args...)
+	if c.warn != nil {
+		c.warn(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+}
+
+// SetVerifier makes the client verify the digest of every tidyset and
+// preprocessed artifact it fetches with v. A nil v disables verification.
+func (c *Client) SetVerifier(v *Verifier) {
+	c.verifier = v
+}
+
+// SetVersionHistory makes the client record the versions it lists in h,
+// from which listings take their creation and state change times. A nil h
+// disables recording.
+func (c *Client) SetVersionHistory(h *VersionHistory) {
+	c.history = h
+}
+
+// SetFetchLog makes the client record every tidyset and preprocessed
+// artifact it fetches in l. A nil l disables recording.
+func (c *Client) SetFetchLog(l *FetchLog) {
+	c.fetchLog = l
+}
+
+// TidysetRequest identifies a tidyset to fetch.
+type TidysetRequest struct {
+	Dataset  string
+	Version  string
+	Tableset string
+	// Filters restrict the rows of the tidyset.
+	Filters []string
+	// Materialize lists the filters to materialize as tables.
+	Materialize []string
+	// Output, if set, is a path to which the fetched data is also copied.
+	Output string
+	// Expected is the digest the data must match, if known.
+	Expected Expected
+}
+
+// Fetched describes data fetched into the local tidydata cache.
+type Fetched struct {
+	// Path is the location of the data in the local cache.
+	Path string `json:"path"`
+	// Version is the resolved version of the data.
+	Version string `json:"version"`
+	// SHA256 is the verified digest of the data, if the client has a
+	// verifier.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// verify checks fetched data with the client's verifier, if any.
+func (c *Client) verify(entry DigestEntry, expected Expected) (string, error) {
+	if c.verifier == nil {
+		return "", nil
+	}
+	entry, err := c.verifier.Verify(entry, expected)
+	return entry.SHA256, err
+}
+
+// Tidyset fetches a tidyset into the local cache. Offline, it returns the
+// most recent earlier fetch of the same tidyset whose file is still cached.
+func (c *Client) Tidyset(ctx *context.T, req TidysetRequest) (Fetched, error) {
+	start := time.Now()
+	record := FetchRecord{
+		Kind:        KindTidyset,
+		Dataset:     req.Dataset,
+		Requested:   req.Version,
+		Tableset:    req.Tableset,
+		Filters:     req.Filters,
+		Materialize: req.Materialize,
+	}
+	p := c.startProgress(record, start)
+	var path, version string
+	var err error
+	if c.offline() {
+		var f FetchRecord
+		f, err = c.offlineFetch(record)
+		path, version, record.Offline = f.Path, f.Version, true
+	} else {
+		path, version, err = p.download(func() (string, string, error) {
+			return c.tidy.GetData(req.Dataset, req.Version, req.Tableset, req.Filters, req.Materialize)
+		})
+	}
+	if err != nil {
+		return Fetched{}, c.finishFetch(ctx, p, record, start, err)
+	}
+	record.Path, record.Version = path, version
+	sum, err := c.verify(DigestEntry{
+		Path:     path,
+		Kind:     KindTidyset,
+		Dataset:  req.Dataset,
+		Version:  version,
+		Tableset: req.Tableset,
+		Filters:  req.Filters,
+	}, req.Expected)
+	if err != nil {
+		return Fetched{}, c.finishFetch(ctx, p, record, start, err)
+	}
+	record.SHA256 = sum
+	if req.Output != "" {
+		if err := p.copy(path, req.Output); err != nil {
+			return Fetched{}, c.finishFetch(ctx, p, record, start, err)
+		}
+	}
+	return Fetched{Path: path, Version: version, SHA256: sum}, c.finishFetch(ctx, p, record, start, nil)
+}
+
+// PreprocessedRequest identifies preprocessed data to fetch.
+type PreprocessedRequest struct {
+	Dataset string
+	Version string
+	// Output, if set, is a path to which the fetched data is also copied.
+	Output string
+	// Expected is the digest the data must match, if known.
+	Expected Expected
+}
+
+// PreprocessedData fetches preprocessed data into the local cache. Offline,
+// it returns the most recent earlier fetch of the same data whose file is
+// still cached.
+func (c *Client) PreprocessedData(ctx *context.T, req PreprocessedRequest) (Fetched, error) {
+	start := time.Now()
+	record := FetchRecord{
+		Kind:      KindPreprocessed,
+		Dataset:   req.Dataset,
+		Requested: req.Version,
+	}
+	p := c.startProgress(record, start)
+	var path, version string
+	var err error
+	if c.offline() {
+		var f FetchRecord
+		f, err = c.offlineFetch(record)
+		path, version, record.Offline = f.Path, f.Version, true
+	} else {
+		path, version, err = p.download(func() (string, string, error) {
+			return c.tidy.GetPreprocessedData(req.Dataset, req.Version)
+		})
+	}
+	if err != nil {
+		return Fetched{}, c.finishFetch(ctx, p, record, start, err)
+	}
+	record.Path, record.Version = path, version
+	sum, err := c.verify(DigestEntry{
+		Path:    path,
+		Kind:    KindPreprocessed,
+		Dataset: req.Dataset,
+		Version: version,
+	}, req.Expected)
+	if err != nil {
+		return Fetched{}, c.finishFetch(ctx, p, record, start, err)
+	}
+	record.SHA256 = sum
+	if req.Output != "" {
+		if err := p.copy(path, req.Output); err != nil {
+			return Fetched{}, c.finishFetch(ctx, p, record, start, err)
+		}
+	}
+	return Fetched{Path: path, Version: version, SHA256: sum}, c.finishFetch(ctx, p, record, start, nil)
+}
+
+// CopyFile copies the file at src to dst.
+func CopyFile(src, dst string) error {
+	return copyFile(src, dst, nil)
+}
+
+// copyFile copies the file at src to dst, calling progress, if set, with
+// the number of bytes copied so far after each write.
+func copyFile(src, dst string, progress func(n int64)) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = out
+	if progress != nil {
+		w = &progressWriter{w: out, progress: progress}
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// AccessRequest identifies a request whose access is to be checked.
+type AccessRequest struct {
+	// Identity is the blessing string to check.
+	Identity string   `json:"identity"`
+	Dataset  string   `json:"dataset"`
+	Version  string   `json:"version"`
+	Tableset string   `json:"tableset"`
+	Filters  []string `json:"filters,omitempty"`
+}
+
+// CheckAccess returns nil if req.Identity may fetch the requested tidyset.
+func (c *Client) CheckAccess(ctx *context.T, req AccessRequest) error {
+	return c.tidy.CheckAccess(req.Identity, req.Dataset, req.Version, req.Tableset, req.Filters)
+}
+
+// ResolveVersion checks that version, which may be an alias, exists in
+// dataset and returns what the server resolves it to.
+func (c *Client) ResolveVersion(ctx *context.T, dataset, version string) (string, error) {
+	var v string
+	err := c.cached(MetaVersions, []string{"resolve", dataset, version}, &v, func() error {
+		resolved, err := c.tidy.GetVersionFor(version, dataset)
+		v = fmt.Sprint(resolved)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if c.history != nil && !c.offline() && v != version && c.history.ObserveAlias(dataset, version, v, time.Now().UTC()) {
+		return v, c.history.Save()
+	}
+	return v, nil
+}
+
+// Orders in which versions can be listed.
+const (
+	// SortNatural orders versions by version string, comparing runs of
+	// digits numerically so that v10 follows v9.
+	SortNatural = "natural"
+	// SortFirstSeen orders versions by the time the client first observed
+	// them.
+	SortFirstSeen = "first_seen"
+	// SortStateSeen orders versions by the time the client first observed
+	// them in their current state.
+	SortStateSeen = "state_seen"
+)
+
+// VersionsRequest selects the versions of a dataset to list.
+type VersionsRequest struct {
+	Dataset string
+	// MinState is the minimum publish state of listed versions.
+	MinState vdl.State
+	// WithAlias restricts the listing to versions that have aliases, one
+	// listing per alias.
+	WithAlias bool
+	// Sort is SortNatural, SortFirstSeen or SortStateSeen. If empty, aliased
+	// versions are listed in server order and others in natural order.
+	Sort string
+	// Since and Until, if set, restrict the listing to versions first seen
+	// in [Since, Until).
+	Since, Until time.Time
+	// LatestPerState lists only the last version, in sort order, of each
+	// state.
+	LatestPerState bool
+	// Offset and Limit page through the listing. A zero Limit lists all
+	// versions.
+	Offset, Limit int
+}
+
+// VersionListing describes a version of a dataset. The tidy server does not
+// record when versions are created or change state, so FirstSeen and
+// StateSeen are the times the version and its current state were first
+// observed by this client, as kept in its local version history, and are
+// zero if the client keeps none.
+type VersionListing struct {
+	Alias       string
+	Version     string
+	State       vdl.State
+	Description string
+	FirstSeen   time.Time
+	StateSeen   time.Time
+}
+
+// MarshalJSON encodes the listing with its state as a string.
+func (v VersionListing) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Alias       string `json:"alias,omitempty"`
+		Version     string `json:"version"`
+		State       string `json:"state"`
+		Description string `json:"description,omitempty"`
+		FirstSeen   string `json:"first_seen,omitempty"`
+		StateSeen   string `json:"state_seen,omitempty"`
+	}{v.Alias, v.Version, v.State.String(), v.Description, formatTime(v.FirstSeen), formatTime(v.StateSeen)})
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// NaturalLess reports whether version a sorts before version b, comparing
+// runs of digits numerically.
+func NaturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// ListVersions lists the versions of a dataset.
+func (c *Client) ListVersions(ctx *context.T, req VersionsRequest) ([]VersionListing, error) {
+	switch req.Sort {
+	case "", SortNatural, SortFirstSeen, SortStateSeen:
+	default:
+		return nil, fmt.Errorf("unsupported sort order %q: must be %s, %s or %s", req.Sort, SortNatural, SortFirstSeen, SortStateSeen)
+	}
+	aliased, err := c.aliasedVersions(req.Dataset)
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]string{}
+	for _, v := range aliased {
+		current[v.Alias] = v.Version
+	}
+	var listings []VersionListing
+	if req.WithAlias {
+		for _, v := range aliased {
+			if v.State >= req.MinState {
+				listings = append(listings, VersionListing{Alias: v.Alias, Version: v.Version, State: v.State, Description: v.Description})
+			}
+		}
+	} else {
+		versions, err := c.versionsAt(req.Dataset, req.MinState)
+		if err != nil {
+			return nil, err
+		}
+		aliases := map[string][]string{}
+		descriptions := map[string]string{}
+		for _, v := range aliased {
+			aliases[v.Version] = append(aliases[v.Version], v.Alias)
+			if v.Description != "" {
+				descriptions[v.Version] = v.Description
+			}
+		}
+		for _, v := range versions {
+			listings = append(listings, VersionListing{
+				Alias:       strings.Join(aliases[v.Version], ","),
+				Version:     v.Version,
+				State:       v.State,
+				Description: descriptions[v.Version],
+			})
+		}
+		if req.Sort == "" {
+			req.Sort = SortNatural
+		}
+	}
+	if err := c.observeVersions(req.Dataset, listings, current); err != nil {
+		return nil, err
+	}
+
+	var selected []VersionListing
+	for _, l := range listings {
+		if (!req.Since.IsZero() && l.FirstSeen.Before(req.Since)) || (!req.Until.IsZero() && !l.FirstSeen.Before(req.Until)) {
+			continue
+		}
+		selected = append(selected, l)
+	}
+	listings = selected
+	switch req.Sort {
+	case "":
+	case SortNatural:
+		sort.SliceStable(listings, func(i, j int) bool { return NaturalLess(listings[i].Version, listings[j].Version) })
+	case SortFirstSeen:
+		sort.SliceStable(listings, func(i, j int) bool { return listings[i].FirstSeen.Before(listings[j].FirstSeen) })
+	case SortStateSeen:
+		sort.SliceStable(listings, func(i, j int) bool { return listings[i].StateSeen.Before(listings[j].StateSeen) })
+	}
+	if req.LatestPerState {
+		latest := map[vdl.State]VersionListing{}
+		for _, l := range listings {
+			latest[l.State] = l
+		}
+		listings = listings[:0]
+		for _, l := range latest {
+			listings = append(listings, l)
+		}
+		sort.Slice(listings, func(i, j int) bool { return listings[i].State < listings[j].State })
+	}
+	if req.Offset > 0 {
+		if req.Offset >= len(listings) {
+			return nil, nil
+		}
+		listings = listings[req.Offset:]
+	}
+	if req.Limit > 0 && req.Limit < len(listings) {
+		listings = listings[:req.Limit]
+	}
+	return listings, nil
+}
+
+// versionEntry is a version as listed by the server, in a form that can be
+// cached.
+type versionEntry struct {
+	Alias       string    `json:"alias,omitempty"`
+	Version     string    `json:"version"`
+	State       vdl.State `json:"state"`
+	Description string    `json:"description,omitempty"`
+}
+
+// aliasedVersions lists the versions of a dataset that have aliases, once
+// per alias.
+func (c *Client) aliasedVersions(dataset string) ([]versionEntry, error) {
+	var entries []versionEntry
+	err := c.cached(MetaVersions, []string{"aliased", dataset}, &entries, func() error {
+		versions, err := c.tidy.ListAliasedVersions(dataset)
+		for _, v := range versions {
+			entries = append(entries, versionEntry{Alias: v.Alias, Version: v.Version, State: v.State, Description: v.Description})
+		}
+		return err
+	})
+	return entries, err
+}
+
+// versionsAt lists the versions of a dataset at or above minState. With a
+// metadata cache, every version is fetched, and cached, and the listing
+// filtered locally, so that offline listings can use any minimum state.
+func (c *Client) versionsAt(dataset string, minState vdl.State) ([]versionEntry, error) {
+	if c.cache == nil {
+		versions, err := c.tidy.ListVersionsAt(dataset, minState)
+		if err != nil {
+			return nil, err
+		}
+		var entries []versionEntry
+		for _, v := range versions {
+			entries = append(entries, versionEntry{Version: v.Version, State: v.State})
+		}
+		return entries, nil
+	}
+	var all []versionEntry
+	err := c.cached(MetaVersions, []string{"versions", dataset}, &all, func() error {
+		versions, err := c.tidy.ListVersionsAt(dataset, lowestState())
+		for _, v := range versions {
+			all = append(all, versionEntry{Version: v.Version, State: v.State})
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var entries []versionEntry
+	for _, v := range all {
+		if v.State >= minState {
+			entries = append(entries, v)
+		}
+	}
+	return entries, nil
+}
+
+// ErrNoVersion is returned by LatestVersion if no version qualifies.
+var ErrNoVersion = errors.New("no version qualifies")
+
+// LatestRequest selects the latest version of a dataset.
+type LatestRequest struct {
+	Dataset string
+	// MinState is the minimum publish state of the version.
+	MinState vdl.State
+	// Tableset, if set, restricts the selection to versions that include
+	// it.
+	Tableset string
+	// AsOf, if set, selects the version using the publish states recorded
+	// in the client's version history at that time; see
+	// ResolveVersionAsOf.
+	AsOf time.Time
+}
+
+// LatestVersion returns the last version, in natural order, at or above
+// req.MinState that is not failed and includes req.Tableset. It returns an
+// error wrapping ErrNoVersion if there is none.
+func (c *Client) LatestVersion(ctx *context.T, req LatestRequest) (string, error) {
+	if !req.AsOf.IsZero() {
+		return c.latestVersionAsOf(ctx, req)
+	}
+	versions, err := c.ListVersions(ctx, VersionsRequest{Dataset: req.Dataset, MinState: req.MinState, Sort: SortNatural})
+	if err != nil {
+		return "", err
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if IsFailedState(v.State) {
+			continue
+		}
+		if req.Tableset != "" {
+			tablesets, err := c.ListTablesets(ctx, req.Dataset, v.Version)
+			if err != nil {
+				return "", err
+			}
+			if !contains(tablesets, req.Tableset) {
+				continue
+			}
+		}
+		return v.Version, nil
+	}
+	if req.Tableset != "" {
+		return "", fmt.Errorf("%s: %w at or above %s with tableset %s", req.Dataset, ErrNoVersion, req.MinState.String(), req.Tableset)
+	}
+	return "", fmt.Errorf("%s: %w at or above %s", req.Dataset, ErrNoVersion, req.MinState.String())
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// observeVersions records listings and the aliases of a dataset in the
+// client's version history, if it has one, and sets the listings' times
+// from it.
+func (c *Client) observeVersions(dataset string, listings []VersionListing, aliases map[string]string) error {
+	if c.history == nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	// Offline listings are not observations of the server, so they only
+	// take their times from the history.
+	changed := !c.offline() && c.history.ObserveAliases(dataset, aliases, now)
+	for i, l := range listings {
+		if !c.offline() && c.history.Observe(dataset, l.Version, l.State.String(), now) {
+			changed = true
+		}
+		if r := c.history.Record(dataset, l.Version); r != nil {
+			listings[i].FirstSeen = r.FirstSeen
+			listings[i].StateSeen = r.States[len(r.States)-1].Since
+		}
+	}
+	if changed {
+		return c.history.Save()
+	}
+	return nil
+}
+
+// VersionDescription returns the description recorded for a version in its
+// aliased version listing, or an empty string if the version has no alias.
+func (c *Client) VersionDescription(ctx *context.T, dataset, version string) (string, error) {
+	versions, err := c.aliasedVersions(dataset)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if v.Version == version && v.Description != "" {
+			return v.Description, nil
+		}
+	}
+	return "", nil
+}
+
+// ListDatasets lists all datasets.
+func (c *Client) ListDatasets(ctx *context.T) ([]string, error) {
+	var datasets []string
+	err := c.cached(MetaDatasets, []string{"datasets"}, &datasets, func() (err error) {
+		datasets, err = c.tidy.ListDatasets()
+		return err
+	})
+	return datasets, err
+}
+
+// ListTablesets lists the tablesets of a dataset version.
+func (c *Client) ListTablesets(ctx *context.T, dataset, version string) ([]string, error) {
+	var tablesets []string
+	err := c.cached(MetaStructure, []string{"tablesets", dataset, version}, &tablesets, func() (err error) {
+		tablesets, err = c.tidy.ListTablesets(dataset, version)
+		return err
+	})
+	return tablesets, err
+}
+
+// ListFilters lists the filters of a dataset version.
+func (c *Client) ListFilters(ctx *context.T, dataset, version string) ([]string, error) {
+	var names []string
+	err := c.cached(MetaStructure, []string{"filters", dataset, version}, &names, func() error {
+		filters, err := c.tidy.ListFilters(dataset, version)
+		for _, f := range filters {
+			names = append(names, fmt.Sprint(f))
+		}
+		return err
+	})
+	return names, err
+}
+
+// ListTables lists the tables of a tableset.
+func (c *Client) ListTables(ctx *context.T, dataset, version, tableset string) ([]string, error) {
+	var names []string
+	err := c.cached(MetaStructure, []string{"tables", dataset, version, tableset}, &names, func() error {
+		tables, err := c.tidy.ListTables(dataset, version, tableset)
+		for _, t := range tables {
+			names = append(names, fmt.Sprint(t))
+		}
+		return err
+	})
+	return names, err
+}
+
+// ListAliases lists the aliases of a dataset version.
+func (c *Client) ListAliases(ctx *context.T, dataset, version string) ([]string, error) {
+	var names []string
+	err := c.cached(MetaVersions, []string{"aliases", dataset, version}, &names, func() error {
+		aliases, err := c.tidy.ListAliases(dataset, version)
+		for _, a := range aliases {
+			names = append(names, fmt.Sprint(a))
+		}
+		return err
+	})
+	return names, err
+}
+
+// ListSnapshots lists the clinical data snapshots of a dataset version.
+func (c *Client) ListSnapshots(ctx *context.T, dataset, version string) ([]string, error) {
+	var names []string
+	err := c.cached(MetaStructure, []string{"snapshots", dataset, version}, &names, func() error {
+		snapshots, err := c.tidy.ListSnapshots(dataset, version)
+		for _, s := range snapshots {
+			names = append(names, fmt.Sprint(s))
+		}
+		return err
+	})
+	return names, err
+}
+
+// DescribeDataset returns the description of a dataset.
+func (c *Client) DescribeDataset(ctx *context.T, dataset string) (string, error) {
+	var description string
+	err := c.cached(MetaDescriptions, []string{"dataset", dataset}, &description, func() (err error) {
+		description, err = c.tidy.DescribeDataset(dataset)
+		return err
+	})
+	return description, err
+}
+
+// DescribeVersion returns the description of a dataset version.
+func (c *Client) DescribeVersion(ctx *context.T, dataset, version string) (string, error) {
+	var description string
+	err := c.cached(MetaDescriptions, []string{"version", dataset, version}, &description, func() (err error) {
+		description, err = c.tidy.DescribeVersion(dataset, version)
+		return err
+	})
+	return description, err
+}
+
+// DescribeTableset returns the description of a tableset.
+func (c *Client) DescribeTableset(ctx *context.T, dataset, version, tableset string) (string, error) {
+	var description string
+	err := c.cached(MetaDescriptions, []string{"tableset", dataset, version, tableset}, &description, func() (err error) {
+		description, err = c.tidy.DescribeTableset(dataset, version, tableset)
+		return err
+	})
+	return description, err
+}
+
+// FilterDescription describes a filter.
+type FilterDescription struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	QueryString string `json:"query_string"`
+}
+
+// DescribeFilter describes a filter of a dataset version.
+func (c *Client) DescribeFilter(ctx *context.T, dataset, version, filter string) (FilterDescription, error) {
+	desc := FilterDescription{Name: filter}
+	err := c.cached(MetaDescriptions, []string{"filter", dataset, version, filter}, &desc, func() (err error) {
+		desc.Description, desc.QueryString, err = c.tidy.DescribeFilter(dataset, version, filter)
+		return err
+	})
+	return desc, err
+}
+
+// TableDescription describes a table of a tableset.
+type TableDescription struct {
+	Name    string   `json:"name"`
+	NumRows int64    `json:"num_rows"`
+	Columns []string `json:"columns"`
+}
+
+// DescribeTable describes a table of a tableset.
+func (c *Client) DescribeTable(ctx *context.T, dataset, version, tableset, table string) (TableDescription, error) {
+	desc := TableDescription{Name: table}
+	err := c.cached(MetaDescriptions, []string{"table", dataset, version, tableset, table}, &desc, func() error {
+		info, err := c.tidy.DescribeTable(dataset, version, tableset, table)
+		if err != nil {
+			return err
+		}
+		desc.NumRows = int64(info.NumRows)
+		for _, col := range info.Columns {
+			desc.Columns = append(desc.Columns, fmt.Sprint(col))
+		}
+		return nil
+	})
+	return desc, err
+}
+
+// ColumnDescription describes a column of a table.
+type ColumnDescription struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Rule        string `json:"rule,omitempty"`
+}
+
+// DescribeColumn describes a column of a table. It is currently only
+// supported for clinical tables.
+func (c *Client) DescribeColumn(ctx *context.T, dataset, version, table, column string) (ColumnDescription, error) {
+	desc := ColumnDescription{Name: column}
+	err := c.cached(MetaDescriptions, []string{"column", dataset, version, table, column}, &desc, func() error {
+		description, err := c.tidy.DescribeColumn(dataset, version, table, column)
+		if err != nil {
+			return err
+		}
+		if len(description) < 2 {
+			return errors.New("malformed column description")
+		}
+		desc.Description, desc.Rule = description[0], description[1]
+		return nil
+	})
+	return desc, err
+}
+
+// AddVersion adds a new version in the generating state.
+func (c *Client) AddVersion(ctx *context.T, dataset, version string) error {
+	return c.admin(ctx, OpAddVersion, []string{dataset, version}, func() error {
+		return c.tidy.AddVersion(dataset, version, vdl.StateGenerating)
+	})
+}
+
+// UpdateVersionState sets the publish state of a version.
+func (c *Client) UpdateVersionState(ctx *context.T, dataset, version string, state vdl.State) error {
+	return c.admin(ctx, OpUpdateState, []string{dataset, version, state.String()}, func() error {
+		return c.tidy.UpdateVersionState(dataset, version, state)
+	})
+}
+
+// UpdateVersionDescription sets the description of a version.
+func (c *Client) UpdateVersionDescription(ctx *context.T, dataset, version, description string) error {
+	return c.admin(ctx, OpUpdateDescription, []string{dataset, version, description}, func() error {
+		return c.tidy.UpdateVersionDescription(dataset, version, description)
+	})
+}
+
+// replaceDescriptionLine sets line as the one line of the description of a
+// version that matches match, removing any others.
+func (c *Client) replaceDescriptionLine(ctx *context.T, dataset, version string, match *regexp.Regexp, line string) error {
+	description, err := c.DescribeVersion(ctx, dataset, version)
+	if err != nil {
+		return err
+	}
+	var lines []string
+	for _, l := range strings.Split(description, "\n") {
+		if !match.MatchString(l) {
+			lines = append(lines, l)
+		}
+	}
+	description = strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	if description != "" {
+		description += "\n"
+	}
+	return c.UpdateVersionDescription(ctx, dataset, version, description+line)
+}
+
+// AddVersionAlias adds an alias for a version.
+func (c *Client) AddVersionAlias(ctx *context.T, dataset, version, alias string) error {
+	return c.admin(ctx, OpAddAlias, []string{dataset, version, alias}, func() error {
+		return c.tidy.AddVersionAlias(dataset, version, alias)
+	})
+}
+
+// UpdateVersionAlias renames an alias.
+func (c *Client) UpdateVersionAlias(ctx *context.T, dataset, alias, newAlias string) error {
+	return c.admin(ctx, OpUpdateAlias, []string{dataset, alias, newAlias}, func() error {
+		return c.tidy.UpdateVersionAlias(dataset, alias, newAlias)
+	})
+}
+
+// RemoveVersionAlias removes an alias.
+func (c *Client) RemoveVersionAlias(ctx *context.T, dataset, alias string) error {
+	return c.admin(ctx, OpRemoveAlias, []string{dataset, alias}, func() error {
+		return c.tidy.RemoveVersionAlias(dataset, alias)
+	})
+}
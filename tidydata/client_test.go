@@ -0,0 +1,27 @@
+package tidydata
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNaturalLess(t *testing.T) {
+	// Each version sorts before the next.
+	ordered := []string{"", "1", "9a", "10", "2023_12", "2024_01", "2024_02", "v1", "v1.9", "v1.10", "v1a", "v2", "v10", "v10b", "vx"}
+	shuffled := append([]string{}, ordered...)
+	rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	sort.Slice(shuffled, func(i, j int) bool { return NaturalLess(shuffled[i], shuffled[j]) })
+	if !reflect.DeepEqual(shuffled, ordered) {
+		t.Errorf("natural order is %q, want %q", shuffled, ordered)
+	}
+
+	// Leading zeros don't change a number, so neither version sorts first.
+	if NaturalLess("v02", "v2") || NaturalLess("v2", "v02") {
+		t.Error("v02 and v2 are ordered, want them equal")
+	}
+	if NaturalLess("v2", "v2") {
+		t.Error("v2 sorts before itself")
+	}
+}
@@ -0,0 +1,106 @@
+package tidydata
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"v.io/v23/context"
+)
+
+// Deprecation marks a version as going away. It is kept on the server as a
+// line of the version description, such as
+//
+//	deprecated: sunset=2025-06-30 replacement=v12
+//
+// so that every client sees it.
+type Deprecation struct {
+	// Sunset is the last day, in UTC, on which the version is supported. It
+	// may be removed from the day after.
+	Sunset time.Time `json:"sunset"`
+	// Replacement is the version to use instead, if any.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+var deprecationLine = regexp.MustCompile(`(?i)^\s*deprecated\s*:(.*)$`)
+
+// ParseDeprecation returns the deprecation recorded in a version
+// description, or nil if there is none.
+func ParseDeprecation(description string) (*Deprecation, error) {
+	for _, line := range strings.Split(description, "\n") {
+		m := deprecationLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		d := &Deprecation{}
+		for _, field := range strings.Fields(m[1]) {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch kv[0] {
+			case "sunset":
+				t, err := ParseTime(kv[1])
+				if err != nil {
+					return nil, fmt.Errorf("couldn't parse deprecation %q: %v", line, err)
+				}
+				d.Sunset = t
+			case "replacement":
+				d.Replacement = kv[1]
+			}
+		}
+		return d, nil
+	}
+	return nil, nil
+}
+
+// String formats d as a description line.
+func (d *Deprecation) String() string {
+	s := "deprecated: sunset=" + d.Sunset.Format("2006-01-02")
+	if d.Replacement != "" {
+		s += " replacement=" + d.Replacement
+	}
+	return s
+}
+
+// Expired reports whether the sunset day has ended at now.
+func (d *Deprecation) Expired(now time.Time) bool {
+	y, m, day := d.Sunset.UTC().Date()
+	return !now.Before(time.Date(y, m, day+1, 0, 0, 0, 0, time.UTC))
+}
+
+// Warning describes the deprecation of a version to its users.
+func (d *Deprecation) Warning(dataset, version string, now time.Time) string {
+	verb := "will be sunset on"
+	if d.Expired(now) {
+		verb = "was sunset on"
+	}
+	s := fmt.Sprintf("%s %s is deprecated and %s %s", dataset, version, verb, d.Sunset.Format("2006-01-02"))
+	if d.Replacement != "" {
+		s += "; use " + d.Replacement + " instead"
+	}
+	return s
+}
+
+// Deprecate records a deprecation in the description of a version,
+// replacing any earlier one.
+func (c *Client) Deprecate(ctx *context.T, dataset, version string, d Deprecation) error {
+	return c.replaceDescriptionLine(ctx, dataset, version, deprecationLine, d.String())
+}
+
+// CheckDeprecation returns the deprecation of a version, which may be an
+// alias, and the version it resolves to. The deprecation is nil if the
+// version is not deprecated.
+func (c *Client) CheckDeprecation(ctx *context.T, dataset, version string) (*Deprecation, string, error) {
+	resolved, err := c.ResolveVersion(ctx, dataset, version)
+	if err != nil {
+		return nil, "", err
+	}
+	description, err := c.DescribeVersion(ctx, dataset, resolved)
+	if err != nil {
+		return nil, "", err
+	}
+	d, err := ParseDeprecation(description)
+	return d, resolved, err
+}
@@ -0,0 +1,69 @@
+package tidydata
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testSunset = time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+
+func TestParseDeprecation(t *testing.T) {
+	for _, test := range []struct {
+		name, description string
+		want              *Deprecation
+	}{
+		{"empty", "", nil},
+		{"other lines", "CCGA training set\nupstream: ccga@v3", nil},
+		{"full", "deprecated: sunset=2025-06-30 replacement=v12", &Deprecation{Sunset: testSunset, Replacement: "v12"}},
+		{"indented", "notes\n  Deprecated : sunset=2025-06-30", &Deprecation{Sunset: testSunset}},
+		{"unknown fields", "deprecated: replacement=v12 junk", &Deprecation{Replacement: "v12"}},
+		{"timestamp", "deprecated: sunset=2025-06-30T00:00:00Z", &Deprecation{Sunset: testSunset}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseDeprecation(test.description)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseDeprecation(%q) = %+v, want %+v", test.description, got, test.want)
+			}
+		})
+	}
+	if d, err := ParseDeprecation("deprecated: sunset=June"); err == nil {
+		t.Errorf("ParseDeprecation of a bad sunset = %+v, want an error", d)
+	}
+}
+
+func TestDeprecationString(t *testing.T) {
+	d := &Deprecation{Sunset: testSunset, Replacement: "v12"}
+	got, err := ParseDeprecation("notes\n" + d.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Errorf("ParseDeprecation(%q) = %+v, want %+v", d.String(), got, d)
+	}
+}
+
+func TestDeprecationExpired(t *testing.T) {
+	d := &Deprecation{Sunset: testSunset}
+	// The version is supported through the whole of its sunset day.
+	for _, now := range []time.Time{
+		testSunset.Add(-time.Hour),
+		testSunset,
+		testSunset.Add(24*time.Hour - time.Nanosecond),
+	} {
+		if d.Expired(now) {
+			t.Errorf("expired at %v, want supported through %v", now, testSunset.Format("2006-01-02"))
+		}
+	}
+	for _, now := range []time.Time{
+		testSunset.Add(24 * time.Hour),
+		time.Date(2025, 7, 1, 1, 0, 0, 0, time.FixedZone("PDT", -7*3600)),
+	} {
+		if !d.Expired(now) {
+			t.Errorf("not expired at %v", now)
+		}
+	}
+}
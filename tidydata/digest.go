@@ -0,0 +1,263 @@
+package tidydata
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ErrDigestMismatch is returned, wrapped, when fetched data does not match
+// its recorded or expected digest.
+type ErrDigestMismatch struct {
+	Path        string
+	Expected    string
+	Actual      string
+	Quarantined string
+}
+
+func (e *ErrDigestMismatch) Error() string {
+	msg := fmt.Sprintf("%s: sha256 %s does not match expected %s", e.Path, e.Actual, e.Expected)
+	if e.Quarantined != "" {
+		msg += fmt.Sprintf("; moved to %s, fetch again to re-download", e.Quarantined)
+	}
+	return msg
+}
+
+// DigestEntry records the digest of an artifact in the local cache.
+type DigestEntry struct {
+	Path     string   `json:"path"`
+	Kind     string   `json:"kind"`
+	Dataset  string   `json:"dataset"`
+	Version  string   `json:"version"`
+	Tableset string   `json:"tableset,omitempty"`
+	Filters  []string `json:"filters,omitempty"`
+	SHA256   string   `json:"sha256"`
+	Size     int64    `json:"size"`
+	// ModTime is the modification time of the file when it was last
+	// hashed.
+	ModTime time.Time `json:"mod_time"`
+	// Signed is set if the digest was verified against a signature.
+	Signed bool `json:"signed,omitempty"`
+	// Trusted is set if the digest was verified against an expected digest
+	// or signature. Other digests are those of the data as first fetched,
+	// which may already have been wrong.
+	Trusted  bool      `json:"trusted,omitempty"`
+	Recorded time.Time `json:"recorded"`
+}
+
+// Artifact kinds.
+const (
+	KindTidyset      = "tidyset"
+	KindPreprocessed = "preprocessed"
+)
+
+// Expected is the digest, and optionally signature, that fetched data must
+// match. The tidy server does not currently publish digests, so these are
+// supplied out of band, for example from release notes.
+type Expected struct {
+	// SHA256 is the hex-encoded expected digest, if known.
+	SHA256 string
+	// Signature is an ed25519 signature of the raw SHA-256 digest, if
+	// known. It requires Verifier.PublicKey.
+	Signature []byte
+}
+
+// Verifier checks fetched data against digests. The digest of each
+// artifact is recorded in a manifest the first time it is fetched and
+// checked every time the cached file is reused; data whose digest does not
+// match is moved to a quarantine directory.
+//
+// Since the tidy server publishes no digests or signatures, the recorded
+// digests are trusted on first use: they detect changes to the local cache
+// after a fetch, not data that was already wrong when first fetched. Only
+// an Expected digest or signature obtained from the data's publisher
+// establishes the latter, and it overrides a digest recorded without one.
+// Quarantining a file forgets its digest unless it was so verified, so
+// that the next fetch records the digest of the data downloaded afresh.
+type Verifier struct {
+	// ManifestPath is the file in which digests are recorded.
+	ManifestPath string
+	// QuarantineDir receives files whose digests do not match.
+	QuarantineDir string
+	// PublicKey verifies Expected.Signature.
+	PublicKey ed25519.PublicKey
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 digest and size of a file.
+func FileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// loadManifest returns the recorded digests, keyed by path.
+func (v *Verifier) loadManifest() (map[string]DigestEntry, error) {
+	manifest := map[string]DigestEntry{}
+	if err := readJSONFile(v.ManifestPath, &manifest); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Entries returns the recorded digests, sorted by path.
+func (v *Verifier) Entries() ([]DigestEntry, error) {
+	manifest, err := v.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	var entries []DigestEntry
+	for _, e := range manifest {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// quarantine moves the file at path into the quarantine directory and
+// returns its new location.
+func (v *Verifier) quarantine(path string) (string, error) {
+	if err := os.MkdirAll(v.QuarantineDir, 0777); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(v.QuarantineDir, fmt.Sprintf("%s-%s", time.Now().Format("20060102T150405.000000000"), filepath.Base(path)))
+	return dst, os.Rename(path, dst)
+}
+
+// forget removes the recorded digest of path unless it is trusted.
+func (v *Verifier) forget(path string) error {
+	manifest, err := v.loadManifest()
+	if err != nil {
+		return err
+	}
+	if e, ok := manifest[path]; !ok || e.Trusted {
+		return nil
+	}
+	delete(manifest, path)
+	return writeJSONFile(v.ManifestPath, manifest)
+}
+
+// mismatch quarantines path, forgets its untrusted digest and returns the
+// resulting error.
+func (v *Verifier) mismatch(path, expected, actual string) error {
+	err := &ErrDigestMismatch{Path: path, Expected: expected, Actual: actual}
+	if v.QuarantineDir != "" {
+		dst, qerr := v.quarantine(path)
+		if qerr != nil {
+			return fmt.Errorf("%v; quarantine failed: %v", err, qerr)
+		}
+		err.Quarantined = dst
+		if ferr := v.forget(path); ferr != nil {
+			return fmt.Errorf("%v; couldn't forget its digest: %v", err, ferr)
+		}
+	}
+	return err
+}
+
+// Verify checks the fetched artifact described by entry, whose Path is set,
+// against expected or, if expected is empty, against the digest recorded
+// when it was first fetched. On success the digest is recorded. A file
+// whose size and modification time match the recorded ones is not hashed
+// again; VerifyCache rehashes every file.
+func (v *Verifier) Verify(entry DigestEntry, expected Expected) (DigestEntry, error) {
+	info, err := os.Stat(entry.Path)
+	if err != nil {
+		return entry, err
+	}
+	manifest, err := v.loadManifest()
+	if err != nil {
+		return entry, err
+	}
+	prev, ok := manifest[entry.Path]
+	ok = ok && prev.Version == entry.Version
+	unchanged := ok && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime())
+	sum, size := prev.SHA256, prev.Size
+	if !unchanged {
+		if sum, size, err = FileSHA256(entry.Path); err != nil {
+			return entry, err
+		}
+	}
+	if expected.SHA256 != "" && expected.SHA256 != sum {
+		return entry, v.mismatch(entry.Path, expected.SHA256, sum)
+	}
+	if len(expected.Signature) > 0 {
+		if len(v.PublicKey) != ed25519.PublicKeySize {
+			return entry, fmt.Errorf("%s: a public key is required to verify the signature", entry.Path)
+		}
+		raw, _ := hex.DecodeString(sum)
+		if !ed25519.Verify(v.PublicKey, raw, expected.Signature) {
+			return entry, v.mismatch(entry.Path, "a validly signed digest", sum)
+		}
+		entry.Signed = true
+	}
+	if expected.SHA256 != "" || entry.Signed {
+		entry.Trusted = true
+	} else if ok && prev.SHA256 != sum {
+		return entry, v.mismatch(entry.Path, prev.SHA256, sum)
+	} else {
+		entry.Trusted, entry.Signed = ok && prev.Trusted, ok && prev.Signed
+	}
+	if unchanged && (prev.Signed || !entry.Signed) && (prev.Trusted || !entry.Trusted) {
+		return prev, nil
+	}
+	entry.SHA256, entry.Size, entry.ModTime, entry.Recorded = sum, size, info.ModTime(), time.Now()
+	manifest[entry.Path] = entry
+	return entry, writeJSONFile(v.ManifestPath, manifest)
+}
+
+// VerifyResult is the outcome of re-verifying a recorded artifact.
+type VerifyResult struct {
+	Entry DigestEntry `json:"entry"`
+	// Status is "ok", "missing", "mismatch" or "error".
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// VerifyCache recomputes the digests of the recorded artifacts whose paths
+// are given, or of all recorded artifacts if none are, and quarantines any
+// that no longer match.
+func (v *Verifier) VerifyCache(paths ...string) ([]VerifyResult, error) {
+	entries, err := v.Entries()
+	if err != nil {
+		return nil, err
+	}
+	want := map[string]bool{}
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		want[p], want[abs] = true, true
+	}
+	var results []VerifyResult
+	for _, e := range entries {
+		if len(want) > 0 && !want[e.Path] {
+			continue
+		}
+		result := VerifyResult{Entry: e, Status: "ok"}
+		sum, _, err := FileSHA256(e.Path)
+		switch {
+		case os.IsNotExist(err):
+			result.Status = "missing"
+		case err != nil:
+			result.Status, result.Error = "error", err.Error()
+		case sum != e.SHA256:
+			result.Status, result.Error = "mismatch", v.mismatch(e.Path, e.SHA256, sum).Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
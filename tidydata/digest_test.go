@@ -0,0 +1,163 @@
+package tidydata
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// digestFixture is a verifier and a cached file whose contents and
+// modification time tests set.
+type digestFixture struct {
+	t     *testing.T
+	v     *Verifier
+	entry DigestEntry
+	mtime time.Time
+}
+
+func newDigestFixture(t *testing.T) (*digestFixture, func()) {
+	dir, err := ioutil.TempDir("", "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &digestFixture{
+		t:     t,
+		v:     &Verifier{ManifestPath: filepath.Join(dir, "manifest.json"), QuarantineDir: filepath.Join(dir, "quarantine")},
+		entry: DigestEntry{Path: filepath.Join(dir, "data.sqlite"), Kind: KindTidyset, Dataset: "ccga", Version: "v3"},
+		mtime: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	return f, func() { os.RemoveAll(dir) }
+}
+
+// fetch writes data as if it had just been downloaded.
+func (f *digestFixture) fetch(data string) {
+	f.mtime = f.mtime.Add(time.Minute)
+	if err := ioutil.WriteFile(f.entry.Path, []byte(data), 0666); err != nil {
+		f.t.Fatal(err)
+	}
+	if err := os.Chtimes(f.entry.Path, f.mtime, f.mtime); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *digestFixture) recorded() (DigestEntry, bool) {
+	manifest, err := f.v.loadManifest()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	e, ok := manifest[f.entry.Path]
+	return e, ok
+}
+
+func digestOf(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// TestVerifyFirstUse checks digests recorded without an expected digest:
+// they detect later changes to the cache, and are forgotten when the
+// changed file is quarantined so that the data can be fetched again.
+func TestVerifyFirstUse(t *testing.T) {
+	f, cleanup := newDigestFixture(t)
+	defer cleanup()
+
+	f.fetch("abc")
+	first, err := f.v.Verify(f.entry, Expected{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.SHA256 != digestOf("abc") || first.Size != 3 || !first.ModTime.Equal(f.mtime) || first.Trusted {
+		t.Errorf("recorded %+v, want an untrusted digest of abc", first)
+	}
+	if again, err := f.v.Verify(f.entry, Expected{}); err != nil || !again.Recorded.Equal(first.Recorded) {
+		t.Errorf("Verify of unchanged file = %+v, %v, want the recorded entry", again, err)
+	}
+
+	// A change that preserves the size is detected by the mtime.
+	f.fetch("abd")
+	var mismatch *ErrDigestMismatch
+	if _, err := f.v.Verify(f.entry, Expected{}); !errors.As(err, &mismatch) || mismatch.Quarantined == "" {
+		t.Fatalf("Verify of changed file = %v, want a quarantined mismatch", err)
+	}
+	if _, err := os.Stat(f.entry.Path); !os.IsNotExist(err) {
+		t.Errorf("changed file was not quarantined: %v", err)
+	}
+	if e, ok := f.recorded(); ok {
+		t.Errorf("the digest of the quarantined file is still recorded: %+v", e)
+	}
+	f.fetch("xyz")
+	if e, err := f.v.Verify(f.entry, Expected{}); err != nil || e.SHA256 != digestOf("xyz") {
+		t.Errorf("Verify of refetched file = %+v, %v, want its digest recorded", e, err)
+	}
+}
+
+// TestVerifyExpected checks that an expected digest overrides a digest
+// recorded on first use and is kept once verified.
+func TestVerifyExpected(t *testing.T) {
+	f, cleanup := newDigestFixture(t)
+	defer cleanup()
+	good := Expected{SHA256: digestOf("complete")}
+
+	// A truncated first fetch is recorded, but can't be checked.
+	f.fetch("compl")
+	if _, err := f.v.Verify(f.entry, Expected{}); err != nil {
+		t.Fatal(err)
+	}
+	var mismatch *ErrDigestMismatch
+	if _, err := f.v.Verify(f.entry, good); !errors.As(err, &mismatch) || mismatch.Expected != good.SHA256 {
+		t.Fatalf("Verify of truncated file = %v, want a mismatch against the expected digest", err)
+	}
+	f.fetch("complete")
+	e, err := f.v.Verify(f.entry, good)
+	if err != nil || !e.Trusted {
+		t.Fatalf("Verify of complete file = %+v, %v, want a trusted digest", e, err)
+	}
+
+	// The verified digest is kept when a later change is quarantined, so
+	// that bad data fetched again is still caught.
+	f.fetch("tampered")
+	if _, err := f.v.Verify(f.entry, Expected{}); !errors.As(err, &mismatch) {
+		t.Fatalf("Verify of changed file = %v, want a mismatch", err)
+	}
+	if e, ok := f.recorded(); !ok || !e.Trusted {
+		t.Errorf("the verified digest was forgotten: %+v", e)
+	}
+	f.fetch("compl")
+	if _, err := f.v.Verify(f.entry, Expected{}); !errors.As(err, &mismatch) || mismatch.Expected != good.SHA256 {
+		t.Errorf("Verify of truncated refetch = %v, want a mismatch against the verified digest", err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := sha256.Sum256([]byte("abc"))
+	for _, test := range []struct {
+		name   string
+		key    ed25519.PublicKey
+		sig    []byte
+		signed bool
+		err    bool
+	}{
+		{"valid", pub, ed25519.Sign(priv, raw[:]), true, false},
+		{"no key", nil, ed25519.Sign(priv, raw[:]), false, true},
+		{"bad signature", pub, ed25519.Sign(priv, []byte("other")), false, true},
+	} {
+		f, cleanup := newDigestFixture(t)
+		f.v.PublicKey = test.key
+		f.fetch("abc")
+		got, err := f.v.Verify(f.entry, Expected{Signature: test.sig})
+		if (err != nil) != test.err || got.Signed != test.signed || got.Trusted != test.signed {
+			t.Errorf("%s: Verify = %+v, %v, want signed %v, error %v", test.name, got, err, test.signed, test.err)
+		}
+		cleanup()
+	}
+}
@@ -0,0 +1,343 @@
+package tidydata
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	texttemplate "text/template"
+
+	"v.io/v23/context"
+)
+
+// ColumnDoc is the documentation for a single column of a table.
+type ColumnDoc struct {
+	Name        string
+	Description string
+	Rule        string
+}
+
+// TableDoc is the documentation page for a table within a tableset.
+type TableDoc struct {
+	Site     *DocsSite `json:"-"`
+	Tableset string
+	Name     string
+	NumRows  int64
+	Columns  []ColumnDoc
+	Page     string
+}
+
+// TablesetDoc is the documentation page for a tableset.
+type TablesetDoc struct {
+	Site        *DocsSite `json:"-"`
+	Name        string
+	Description string
+	Tables      []*TableDoc
+	Page        string
+}
+
+// FilterDoc is the documentation page for a filter.
+type FilterDoc struct {
+	Site        *DocsSite `json:"-"`
+	Name        string
+	Description string
+	QueryString string
+	Page        string
+}
+
+// SearchEntry is a single entry of the generated site's search index.
+type SearchEntry struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Tableset    string `json:"tableset,omitempty"`
+	Table       string `json:"table,omitempty"`
+	Description string `json:"description,omitempty"`
+	Page        string `json:"page"`
+}
+
+// DocsSite is a data dictionary for a single dataset version.
+type DocsSite struct {
+	Dataset     string
+	Version     string
+	Description string
+	Tablesets   []*TablesetDoc
+	Filters     []*FilterDoc
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// docsPage returns the base name, without extension, of the page documenting
+// the object identified by parts.
+func docsPage(kind string, parts ...string) string {
+	name := kind
+	for _, p := range parts {
+		name += "-" + unsafeFileChars.ReplaceAllString(p, "_")
+	}
+	return name
+}
+
+// BuildDocsSite walks a dataset version and collects the documentation for
+// every tableset, table, column and filter.
+func (c *Client) BuildDocsSite(ctx *context.T, dataset, version string) (*DocsSite, error) {
+	vs, err := c.DescribeStructure(ctx, dataset, version)
+	if err != nil {
+		return nil, err
+	}
+	site := &DocsSite{Dataset: dataset, Version: version, Description: vs.Description}
+	// DescribeColumn takes no tableset, so a column's description is shared
+	// by tables of the same name in different tablesets, and only fetched
+	// once. Each table still lists its own columns.
+	described := map[[2]string]ColumnDoc{}
+	for _, tsName := range vs.TablesetNames() {
+		ts := vs.Tablesets[tsName]
+		tsd := &TablesetDoc{Site: site, Name: tsName, Description: ts.Description, Page: docsPage("tableset", tsName)}
+		for _, tName := range ts.TableNames() {
+			t := ts.Tables[tName]
+			var columns []ColumnDoc
+			for _, col := range t.Columns {
+				key := [2]string{tName, col}
+				cd, ok := described[key]
+				if !ok {
+					cd = ColumnDoc{Name: col}
+					// Column descriptions are only available for some
+					// tables, so a failure here is not fatal.
+					if desc, err := c.DescribeColumn(ctx, dataset, version, tName, col); err == nil {
+						cd.Description, cd.Rule = desc.Description, desc.Rule
+					}
+					described[key] = cd
+				}
+				columns = append(columns, cd)
+			}
+			tsd.Tables = append(tsd.Tables, &TableDoc{
+				Site:     site,
+				Tableset: tsName,
+				Name:     tName,
+				NumRows:  t.NumRows,
+				Columns:  columns,
+				Page:     docsPage("table", tsName, tName),
+			})
+		}
+		site.Tablesets = append(site.Tablesets, tsd)
+	}
+	for _, fName := range vs.FilterNames() {
+		f := vs.Filters[fName]
+		site.Filters = append(site.Filters, &FilterDoc{
+			Site:        site,
+			Name:        fName,
+			Description: f.Description,
+			QueryString: f.QueryString,
+			Page:        docsPage("filter", fName),
+		})
+	}
+	return site, nil
+}
+
+// SearchIndex returns the search index entries for every page and column of
+// the site.
+func (site *DocsSite) SearchIndex() []SearchEntry {
+	var entries []SearchEntry
+	for _, ts := range site.Tablesets {
+		entries = append(entries, SearchEntry{Kind: "tableset", Name: ts.Name, Description: ts.Description, Page: ts.Page + ".html"})
+		for _, t := range ts.Tables {
+			entries = append(entries, SearchEntry{Kind: "table", Name: t.Name, Tableset: ts.Name, Page: t.Page + ".html"})
+			for _, c := range t.Columns {
+				entries = append(entries, SearchEntry{Kind: "column", Name: c.Name, Tableset: ts.Name, Table: t.Name, Description: c.Description, Page: t.Page + ".html#" + c.Name})
+			}
+		}
+	}
+	for _, f := range site.Filters {
+		entries = append(entries, SearchEntry{Kind: "filter", Name: f.Name, Description: f.Description, Page: f.Page + ".html"})
+	}
+	return entries
+}
+
+const docsHTMLTemplates = `
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.}}</title>
+<style>body{font-family:sans-serif;max-width:60em;margin:auto}table{border-collapse:collapse}td,th{border:1px solid #ccc;padding:4px;text-align:left;vertical-align:top}pre{white-space:pre-wrap}</style>
+</head>
+<body>
+{{end}}
+{{define "footer"}}</body>
+</html>
+{{end}}
+{{define "index"}}{{template "header" printf "%s %s data dictionary" .Dataset .Version}}<h1>{{.Dataset}} {{.Version}} data dictionary</h1>
+<pre>{{.Description}}</pre>
+<h2>Search</h2>
+<input id="q" type="search" placeholder="table, column or filter" oninput="search(this.value)">
+<ul id="results"></ul>
+<script src="search_index.js"></script>
+<script>
+function search(q) {
+  q = q.toLowerCase();
+  var out = document.getElementById("results");
+  out.innerHTML = "";
+  if (!q) return;
+  searchIndex.filter(function(e) {
+    return e.name.toLowerCase().indexOf(q) >= 0 || (e.description || "").toLowerCase().indexOf(q) >= 0;
+  }).slice(0, 100).forEach(function(e) {
+    var li = document.createElement("li");
+    var a = document.createElement("a");
+    a.href = e.page;
+    a.textContent = e.kind + " " + [e.tableset, e.table, e.name].filter(Boolean).join(".");
+    li.appendChild(a);
+    if (e.description) li.appendChild(document.createTextNode(": " + e.description));
+    out.appendChild(li);
+  });
+}
+</script>
+<h2>Tablesets</h2>
+<ul>
+{{range .Tablesets}}<li><a href="{{.Page}}.html">{{.Name}}</a> ({{len .Tables}} tables)</li>
+{{end}}</ul>
+<h2>Filters</h2>
+<ul>
+{{range .Filters}}<li><a href="{{.Page}}.html">{{.Name}}</a></li>
+{{end}}</ul>
+{{template "footer"}}{{end}}
+{{define "tableset"}}{{template "header" .Name}}<p><a href="index.html">{{.Site.Dataset}} {{.Site.Version}}</a></p>
+<h1>Tableset {{.Name}}</h1>
+<pre>{{.Description}}</pre>
+<table>
+<tr><th>Table</th><th>Rows</th><th>Columns</th></tr>
+{{range .Tables}}<tr><td><a href="{{.Page}}.html">{{.Name}}</a></td><td>{{.NumRows}}</td><td>{{len .Columns}}</td></tr>
+{{end}}</table>
+{{template "footer"}}{{end}}
+{{define "table"}}{{template "header" .Name}}<p><a href="index.html">{{.Site.Dataset}} {{.Site.Version}}</a> / <a href="{{tablesetPage .Tableset}}.html">{{.Tableset}}</a></p>
+<h1>Table {{.Name}}</h1>
+<p>Number of rows: {{.NumRows}}</p>
+<table>
+<tr><th>Column</th><th>Description</th><th>Rule</th></tr>
+{{range .Columns}}<tr id="{{.Name}}"><td>{{.Name}}</td><td>{{.Description}}</td><td><pre>{{.Rule}}</pre></td></tr>
+{{end}}</table>
+{{template "footer"}}{{end}}
+{{define "filter"}}{{template "header" .Name}}<p><a href="index.html">{{.Site.Dataset}} {{.Site.Version}}</a></p>
+<h1>Filter {{.Name}}</h1>
+<pre>{{.Description}}</pre>
+<h2>Query string</h2>
+<pre>{{.QueryString}}</pre>
+{{template "footer"}}{{end}}
+`
+
+const docsMarkdownTemplates = `
+{{define "index"}}# {{.Dataset}} {{.Version}} data dictionary
+
+{{.Description}}
+
+## Tablesets
+{{range .Tablesets}}
+- [{{.Name}}]({{.Page}}.md) ({{len .Tables}} tables){{end}}
+
+## Filters
+{{range .Filters}}
+- [{{.Name}}]({{.Page}}.md){{end}}
+{{end}}
+{{define "tableset"}}[{{.Site.Dataset}} {{.Site.Version}}](index.md)
+
+# Tableset {{.Name}}
+
+{{.Description}}
+
+| Table | Rows | Columns |
+| --- | --- | --- |
+{{range .Tables}}| [{{.Name}}]({{.Page}}.md) | {{.NumRows}} | {{len .Columns}} |
+{{end}}{{end}}
+{{define "table"}}[{{.Site.Dataset}} {{.Site.Version}}](index.md) / [{{.Tableset}}]({{tablesetPage .Tableset}}.md)
+
+# Table {{.Name}}
+
+Number of rows: {{.NumRows}}
+
+| Column | Description | Rule |
+| --- | --- | --- |
+{{range .Columns}}| {{.Name}} | {{cell .Description}} | {{cell .Rule}} |
+{{end}}{{end}}
+{{define "filter"}}[{{.Site.Dataset}} {{.Site.Version}}](index.md)
+
+# Filter {{.Name}}
+
+{{.Description}}
+
+## Query string
+
+` + "```" + `
+{{.QueryString}}
+` + "```" + `
+{{end}}
+`
+
+var markdownCellReplacer = regexp.MustCompile(`[|\n]`)
+
+var docsFuncs = map[string]interface{}{
+	"tablesetPage": func(name string) string { return docsPage("tableset", name) },
+	"cell":         func(s string) string { return markdownCellReplacer.ReplaceAllString(s, " ") },
+}
+
+// executor is implemented by both text and html templates.
+type executor interface {
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// writeDocsPages renders every page of the site to dir using tmpl, with
+// the given file extension.
+func writeDocsPages(dir, ext string, site *DocsSite, tmpl executor) error {
+	write := func(page, name string, data interface{}) error {
+		f, err := os.Create(filepath.Join(dir, page+"."+ext))
+		if err != nil {
+			return err
+		}
+		if err := tmpl.ExecuteTemplate(f, name, data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	if err := write("index", "index", site); err != nil {
+		return err
+	}
+	for _, ts := range site.Tablesets {
+		if err := write(ts.Page, "tableset", ts); err != nil {
+			return err
+		}
+		for _, t := range ts.Tables {
+			if err := write(t.Page, "table", t); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range site.Filters {
+		if err := write(f.Page, "filter", f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDocsSite writes the HTML and Markdown renderings of site, and its
+// search index, to dir.
+func WriteDocsSite(dir string, site *DocsSite) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	htmlTmpl := htmltemplate.Must(htmltemplate.New("docs").Funcs(docsFuncs).Parse(docsHTMLTemplates))
+	if err := writeDocsPages(dir, "html", site, htmlTmpl); err != nil {
+		return err
+	}
+	mdTmpl := texttemplate.Must(texttemplate.New("docs").Funcs(docsFuncs).Parse(docsMarkdownTemplates))
+	if err := writeDocsPages(dir, "md", site, mdTmpl); err != nil {
+		return err
+	}
+	index, err := json.MarshalIndent(site.SearchIndex(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "search_index.json"), index, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "search_index.js"), []byte(fmt.Sprintf("var searchIndex = %s;\n", index)), 0644)
+}
@@ -0,0 +1,266 @@
+package tidydata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"time"
+
+	"v.io/v23/context"
+)
+
+// FetchRecord records a fetch of data into the local cache.
+type FetchRecord struct {
+	Time time.Time `json:"time"`
+	// Kind is KindTidyset or KindPreprocessed.
+	Kind    string `json:"kind"`
+	Dataset string `json:"dataset"`
+	// Requested is the version or alias given in the request, and Version
+	// the version it resolved to.
+	Requested string   `json:"requested"`
+	Version   string   `json:"version"`
+	Tableset  string   `json:"tableset,omitempty"`
+	Filters   []string `json:"filters,omitempty"`
+	// Materialize lists the filters materialized as tables.
+	Materialize []string `json:"materialize,omitempty"`
+	Path        string   `json:"path,omitempty"`
+	SHA256      string   `json:"sha256,omitempty"`
+	// Size is the size of the fetched file in bytes.
+	Size int64 `json:"size"`
+	// DurationMS is how long the fetch took, in milliseconds.
+	DurationMS int64 `json:"duration_ms"`
+	// CacheHit is set if the file was already in the local cache, that is,
+	// was not modified by the fetch.
+	CacheHit bool `json:"cache_hit"`
+	// Offline is set if the data was served from an earlier fetch without
+	// contacting the server.
+	Offline bool `json:"offline,omitempty"`
+	// User is the local user, and Identity the blessing names of the
+	// caller, that made the fetch.
+	User     string   `json:"user,omitempty"`
+	Identity []string `json:"identity,omitempty"`
+	// Error is set if the fetch failed.
+	Error string `json:"error,omitempty"`
+}
+
+// DefaultFetchLogMaxSize is the default size at which a fetch log is
+// rotated.
+const DefaultFetchLogMaxSize = 8 << 20
+
+// FetchLog is an append-only log of fetches, stored as a file of JSON
+// records, one per line. Once the file reaches MaxSize it is rotated to
+// Path.1, replacing the previous rotated file, so the log, which is read
+// on every fetch that reports progress or is served offline, holds
+// between MaxSize and twice MaxSize of the most recent records.
+type FetchLog struct {
+	Path string
+	// MaxSize is the size in bytes at which the log is rotated. It
+	// defaults to DefaultFetchLogMaxSize.
+	MaxSize int64
+}
+
+// rotated returns the path of the rotated log.
+func (l *FetchLog) rotated() string {
+	return l.Path + ".1"
+}
+
+// Append adds a record to the log, rotating it first if it is full.
+func (l *FetchLog) Append(r FetchRecord) error {
+	max := l.MaxSize
+	if max <= 0 {
+		max = DefaultFetchLogMaxSize
+	}
+	if info, err := os.Stat(l.Path); err == nil && info.Size() >= max {
+		if err := os.Rename(l.Path, l.rotated()); err != nil {
+			return err
+		}
+	}
+	return appendJSONLine(l.Path, r)
+}
+
+// Records returns the records of the log, including its rotated file, in
+// the order they were appended. A missing log has no records.
+func (l *FetchLog) Records() ([]FetchRecord, error) {
+	var records []FetchRecord
+	for _, path := range []string{l.rotated(), l.Path} {
+		err := readJSONLines(path, func(line []byte) error {
+			var r FetchRecord
+			if err := json.Unmarshal(line, &r); err != nil {
+				return err
+			}
+			records = append(records, r)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// FetchQuery selects fetch records. Zero fields match every record.
+type FetchQuery struct {
+	Dataset string
+	Version string
+	// User matches the local user or any of the identity blessings.
+	User string
+	// Since and Until restrict records to those in [Since, Until).
+	Since, Until time.Time
+}
+
+// Matches reports whether r is selected by q.
+func (q FetchQuery) Matches(r FetchRecord) bool {
+	if (q.Dataset != "" && r.Dataset != q.Dataset) || (q.Version != "" && r.Version != q.Version && r.Requested != q.Version) {
+		return false
+	}
+	if (!q.Since.IsZero() && r.Time.Before(q.Since)) || (!q.Until.IsZero() && !r.Time.Before(q.Until)) {
+		return false
+	}
+	if q.User == "" || r.User == q.User {
+		return true
+	}
+	for _, b := range r.Identity {
+		if strings.Contains(b, q.User) {
+			return true
+		}
+	}
+	return false
+}
+
+// Query returns the records selected by q in the order they were appended.
+func (l *FetchLog) Query(q FetchQuery) ([]FetchRecord, error) {
+	records, err := l.Records()
+	if err != nil {
+		return nil, err
+	}
+	var selected []FetchRecord
+	for _, r := range records {
+		if q.Matches(r) {
+			selected = append(selected, r)
+		}
+	}
+	return selected, nil
+}
+
+// Dimensions by which usage can be grouped.
+const (
+	ByDataset  = "dataset"
+	ByVersion  = "version"
+	ByTableset = "tableset"
+	ByUser     = "user"
+	ByKind     = "kind"
+)
+
+// UsageRow summarizes the fetches of one group of a usage report.
+type UsageRow struct {
+	// Group maps each grouping dimension to the group's value.
+	Group     map[string]string `json:"group"`
+	Fetches   int               `json:"fetches"`
+	Failures  int               `json:"failures"`
+	CacheHits int               `json:"cache_hits"`
+	Bytes     int64             `json:"bytes"`
+	// DurationMS is the total time spent fetching, in milliseconds.
+	DurationMS int64     `json:"duration_ms"`
+	First      time.Time `json:"first"`
+	Last       time.Time `json:"last"`
+}
+
+// UsageReport groups fetch records by the given dimensions, which are
+// ByDataset, ByVersion, ByTableset, ByUser or ByKind, and summarizes each
+// group. Rows are ordered by decreasing number of fetches.
+func UsageReport(records []FetchRecord, by []string) ([]UsageRow, error) {
+	rows := map[string]*UsageRow{}
+	for _, r := range records {
+		group := map[string]string{}
+		var key []string
+		for _, dim := range by {
+			var v string
+			switch dim {
+			case ByDataset:
+				v = r.Dataset
+			case ByVersion:
+				v = r.Version
+			case ByTableset:
+				v = r.Tableset
+			case ByUser:
+				v = r.User
+			case ByKind:
+				v = r.Kind
+			default:
+				return nil, fmt.Errorf("can't group usage by %q: must be %s, %s, %s, %s or %s", dim, ByDataset, ByVersion, ByTableset, ByUser, ByKind)
+			}
+			group[dim] = v
+			key = append(key, v)
+		}
+		k := strings.Join(key, "\x00")
+		row := rows[k]
+		if row == nil {
+			row = &UsageRow{Group: group, First: r.Time}
+			rows[k] = row
+		}
+		row.Fetches++
+		if r.Error != "" {
+			row.Failures++
+		}
+		if r.CacheHit {
+			row.CacheHits++
+		}
+		row.Bytes += r.Size
+		row.DurationMS += r.DurationMS
+		if r.Time.Before(row.First) {
+			row.First = r.Time
+		}
+		if r.Time.After(row.Last) {
+			row.Last = r.Time
+		}
+	}
+	var report []UsageRow
+	for _, row := range rows {
+		report = append(report, *row)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Fetches != report[j].Fetches {
+			return report[i].Fetches > report[j].Fetches
+		}
+		return report[i].Last.After(report[j].Last)
+	})
+	return report, nil
+}
+
+// finishFetch ends the progress reporting of a fetch and records it; see
+// logFetch.
+func (c *Client) finishFetch(ctx *context.T, p *fetchProgress, r FetchRecord, start time.Time, err error) error {
+	p.done(r.Path, r.Version, err)
+	return c.logFetch(ctx, r, start, err)
+}
+
+// logFetch records a fetch that started at start and failed with err, if
+// not nil, in the client's fetch log, if it has one. It returns err; a
+// failure to record the fetch is only reported as a warning.
+func (c *Client) logFetch(ctx *context.T, r FetchRecord, start time.Time, err error) error {
+	if c.fetchLog == nil {
+		return err
+	}
+	r.Time = start.UTC()
+	r.DurationMS = int64(time.Since(start) / time.Millisecond)
+	r.Identity = callerBlessings(ctx)
+	if u, uerr := user.Current(); uerr == nil {
+		r.User = u.Username
+	}
+	if r.Path != "" {
+		if info, serr := os.Stat(r.Path); serr == nil {
+			r.Size = info.Size()
+			r.CacheHit = info.ModTime().Before(start)
+		}
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if lerr := c.fetchLog.Append(r); lerr != nil {
+		c.warnf("couldn't record fetch of %s %s in %s: %v", r.Dataset, r.Requested, c.fetchLog.Path, lerr)
+	}
+	return err
+}
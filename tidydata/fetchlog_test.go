@@ -0,0 +1,142 @@
+package tidydata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tempFetchLog returns a fetch log in a new temporary directory and a
+// function that removes it.
+func tempFetchLog(t *testing.T, maxSize int64) (*FetchLog, func()) {
+	dir, err := ioutil.TempDir("", "fetchlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &FetchLog{Path: filepath.Join(dir, "fetches.jsonl"), MaxSize: maxSize}, func() { os.RemoveAll(dir) }
+}
+
+func TestFetchLogQuery(t *testing.T) {
+	l, cleanup := tempFetchLog(t, 0)
+	defer cleanup()
+	t0 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, r := range []FetchRecord{
+		{Time: t0, Dataset: "ccga", Requested: "latest", Version: "v3", User: "alice", Identity: []string{"dev.v.io:u:alice@grail.com"}},
+		{Time: t0.Add(time.Hour), Dataset: "ccga", Requested: "v2", Version: "v2", User: "bob"},
+		{Time: t0.Add(2 * time.Hour), Dataset: "pcr", Requested: "v3", Version: "v3", User: "svc", Identity: []string{"dev.v.io:u:alice@grail.com:pipeline"}},
+	} {
+		if err := l.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Each query is listed with the dataset@version of the records it
+	// selects.
+	for _, test := range []struct {
+		q    FetchQuery
+		want string
+	}{
+		{FetchQuery{}, "ccga@v3 ccga@v2 pcr@v3"},
+		{FetchQuery{Dataset: "ccga"}, "ccga@v3 ccga@v2"},
+		{FetchQuery{Version: "v3"}, "ccga@v3 pcr@v3"},
+		{FetchQuery{Version: "latest"}, "ccga@v3"},
+		{FetchQuery{User: "bob"}, "ccga@v2"},
+		{FetchQuery{User: "alice@grail.com"}, "ccga@v3 pcr@v3"},
+		{FetchQuery{Since: t0.Add(time.Hour)}, "ccga@v2 pcr@v3"},
+		{FetchQuery{Until: t0.Add(time.Hour)}, "ccga@v3"},
+		{FetchQuery{Dataset: "ccga", User: "svc"}, ""},
+	} {
+		records, err := l.Query(test.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range records {
+			got = append(got, r.Dataset+"@"+r.Version)
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("Query(%+v) = %q, want %q", test.q, got, test.want)
+		}
+	}
+}
+
+func TestUsageReport(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	records := []FetchRecord{
+		{Time: t0, Kind: "tableset", Dataset: "ccga", Version: "v3", User: "alice", Size: 10, DurationMS: 100},
+		{Time: t0.Add(time.Hour), Kind: "tableset", Dataset: "ccga", Version: "v3", User: "bob", Size: 10, CacheHit: true},
+		{Time: t0.Add(2 * time.Hour), Kind: "tableset", Dataset: "ccga", Version: "v2", User: "alice", Error: "not found"},
+		{Time: t0.Add(3 * time.Hour), Kind: "tableset", Dataset: "pcr", Version: "v1", User: "bob", Size: 5, DurationMS: 50},
+	}
+	group := func(kv ...string) map[string]string {
+		m := map[string]string{}
+		for i := 0; i < len(kv); i += 2 {
+			m[kv[i]] = kv[i+1]
+		}
+		return m
+	}
+	for name, test := range map[string]struct {
+		by   []string
+		want []UsageRow
+	}{
+		"by dataset": {[]string{ByDataset}, []UsageRow{
+			{Group: group(ByDataset, "ccga"), Fetches: 3, Failures: 1, CacheHits: 1, Bytes: 20, DurationMS: 100, First: t0, Last: t0.Add(2 * time.Hour)},
+			{Group: group(ByDataset, "pcr"), Fetches: 1, Bytes: 5, DurationMS: 50, First: t0.Add(3 * time.Hour), Last: t0.Add(3 * time.Hour)},
+		}},
+		// Ties in the number of fetches are broken by the most recent.
+		"by user": {[]string{ByUser}, []UsageRow{
+			{Group: group(ByUser, "bob"), Fetches: 2, CacheHits: 1, Bytes: 15, DurationMS: 50, First: t0.Add(time.Hour), Last: t0.Add(3 * time.Hour)},
+			{Group: group(ByUser, "alice"), Fetches: 2, Failures: 1, Bytes: 10, DurationMS: 100, First: t0, Last: t0.Add(2 * time.Hour)},
+		}},
+		"by dataset and version": {[]string{ByDataset, ByVersion}, []UsageRow{
+			{Group: group(ByDataset, "ccga", ByVersion, "v3"), Fetches: 2, CacheHits: 1, Bytes: 20, DurationMS: 100, First: t0, Last: t0.Add(time.Hour)},
+			{Group: group(ByDataset, "pcr", ByVersion, "v1"), Fetches: 1, Bytes: 5, DurationMS: 50, First: t0.Add(3 * time.Hour), Last: t0.Add(3 * time.Hour)},
+			{Group: group(ByDataset, "ccga", ByVersion, "v2"), Fetches: 1, Failures: 1, First: t0.Add(2 * time.Hour), Last: t0.Add(2 * time.Hour)},
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := UsageReport(records, test.by)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("UsageReport(%q) = %+v, want %+v", test.by, got, test.want)
+			}
+		})
+	}
+	if _, err := UsageReport(records, []string{"host"}); err == nil {
+		t.Error("UsageReport by an unknown field succeeded")
+	}
+	if got, err := UsageReport(nil, []string{ByDataset}); err != nil || got != nil {
+		t.Errorf("UsageReport(nil) = %+v, %v, want no rows", got, err)
+	}
+}
+
+func TestFetchLogRotation(t *testing.T) {
+	l, cleanup := tempFetchLog(t, 1)
+	defer cleanup()
+	if records, err := l.Records(); err != nil || len(records) != 0 {
+		t.Errorf("Records of a new log = %+v, %v, want none", records, err)
+	}
+	for _, v := range []string{"v1", "v2", "v3"} {
+		if err := l.Append(FetchRecord{Dataset: "ccga", Version: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := l.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each append rotates the previous record out, replacing the one
+	// rotated before it.
+	var got []string
+	for _, r := range records {
+		got = append(got, r.Version)
+	}
+	if want := []string{"v2", "v3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Records = %q, want %q", got, want)
+	}
+}
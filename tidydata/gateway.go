@@ -0,0 +1,548 @@
+package tidydata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+)
+
+// VersionHeader is the HTTP header in which the gateway returns the
+// resolved version of downloaded data.
+const VersionHeader = "X-Tidy-Version"
+
+// GatewayOptions configures the HTTP gateway.
+type GatewayOptions struct {
+	// Authenticate returns the blessing string of the caller of a request,
+	// against which access is checked, or an error if the caller cannot be
+	// authenticated. It is required; see CertificateIdentity and
+	// TokenIdentity.
+	Authenticate func(r *http.Request) (string, error)
+	// Log, if set, receives a line per request.
+	Log io.Writer
+}
+
+// gatewayRoute maps a path pattern, in which "*" matches any single
+// segment, to a handler that is passed the matched segments. Before the
+// handler is called, the caller's access to the dataset, version or
+// tableset named by the first segments is checked according to access.
+type gatewayRoute struct {
+	pattern []string
+	access  gatewayAccess
+	handle  func(g *gateway, w http.ResponseWriter, r *http.Request, caller string, params []string) error
+}
+
+// gatewayAccess is the access a caller needs to be served a route.
+type gatewayAccess int
+
+const (
+	// accessChecked routes check access themselves.
+	accessChecked gatewayAccess = iota
+	// accessDataset routes require access to some version of the dataset.
+	accessDataset
+	// accessVersion routes require access to some tableset of the version.
+	accessVersion
+	// accessTableset routes require access to the tableset.
+	accessTableset
+)
+
+type gateway struct {
+	ctx    *context.T
+	client *Client
+	opts   GatewayOptions
+}
+
+// httpError is an error with an HTTP status code.
+type httpError struct {
+	code int
+	err  error
+}
+
+func (e httpError) Error() string { return e.err.Error() }
+
+// NewGateway returns an http.Handler that exposes the client's list,
+// describe, check-access, resolve and download operations as REST
+// endpoints with JSON responses. Every request must be authenticated by
+// opts.Authenticate. All calls are made with ctx, and thus under the
+// gateway's identity, so the caller's identity is checked with CheckAccess
+// on every request: data is served only if the caller may fetch it, a
+// tableset's metadata only if the caller may fetch the tableset, and a
+// version's or dataset's metadata only if the caller may fetch, without
+// filters, some tableset of the version or of one of the dataset's
+// versions. Dataset and version listings omit what the caller may not
+// see. Aliases are resolved once per request, and access is checked for
+// the version that is served.
+//
+//	GET /v1/datasets
+//	GET /v1/datasets/{dataset}
+//	GET /v1/datasets/{dataset}/versions?min_state=tested&with_alias=false
+//	GET /v1/datasets/{dataset}/versions/{version}
+//	GET /v1/datasets/{dataset}/versions/{version}/resolve
+//	GET /v1/datasets/{dataset}/versions/{version}/aliases
+//	GET /v1/datasets/{dataset}/versions/{version}/snapshots
+//	GET /v1/datasets/{dataset}/versions/{version}/filters
+//	GET /v1/datasets/{dataset}/versions/{version}/filters/{filter}
+//	GET /v1/datasets/{dataset}/versions/{version}/tables/{table}/columns/{column}
+//	GET /v1/datasets/{dataset}/versions/{version}/preprocessed
+//	GET /v1/datasets/{dataset}/versions/{version}/tablesets
+//	GET /v1/datasets/{dataset}/versions/{version}/tablesets/{tableset}
+//	GET /v1/datasets/{dataset}/versions/{version}/tablesets/{tableset}/tables
+//	GET /v1/datasets/{dataset}/versions/{version}/tablesets/{tableset}/tables/{table}
+//	GET /v1/datasets/{dataset}/versions/{version}/tablesets/{tableset}/access?filters=a,b
+//	GET /v1/datasets/{dataset}/versions/{version}/tablesets/{tableset}/data?filters=a,b&materialize=c
+func NewGateway(ctx *context.T, client *Client, opts GatewayOptions) (http.Handler, error) {
+	if opts.Authenticate == nil {
+		return nil, errors.New("the gateway needs a way to authenticate callers")
+	}
+	return &gateway{ctx: ctx, client: client, opts: opts}, nil
+}
+
+var gatewayRoutes = []gatewayRoute{
+	{[]string{"v1", "datasets"}, accessChecked, (*gateway).listDatasets},
+	{[]string{"v1", "datasets", "*"}, accessDataset, (*gateway).describeDataset},
+	{[]string{"v1", "datasets", "*", "versions"}, accessChecked, (*gateway).listVersions},
+	{[]string{"v1", "datasets", "*", "versions", "*"}, accessVersion, (*gateway).describeVersion},
+	{[]string{"v1", "datasets", "*", "versions", "*", "resolve"}, accessVersion, (*gateway).resolve},
+	{[]string{"v1", "datasets", "*", "versions", "*", "aliases"}, accessVersion, (*gateway).listAliases},
+	{[]string{"v1", "datasets", "*", "versions", "*", "snapshots"}, accessVersion, (*gateway).listSnapshots},
+	{[]string{"v1", "datasets", "*", "versions", "*", "filters"}, accessVersion, (*gateway).listFilters},
+	{[]string{"v1", "datasets", "*", "versions", "*", "filters", "*"}, accessVersion, (*gateway).describeFilter},
+	{[]string{"v1", "datasets", "*", "versions", "*", "tables", "*", "columns", "*"}, accessVersion, (*gateway).describeColumn},
+	{[]string{"v1", "datasets", "*", "versions", "*", "preprocessed"}, accessChecked, (*gateway).preprocessed},
+	{[]string{"v1", "datasets", "*", "versions", "*", "tablesets"}, accessVersion, (*gateway).listTablesets},
+	{[]string{"v1", "datasets", "*", "versions", "*", "tablesets", "*"}, accessTableset, (*gateway).describeTableset},
+	{[]string{"v1", "datasets", "*", "versions", "*", "tablesets", "*", "tables"}, accessTableset, (*gateway).listTables},
+	{[]string{"v1", "datasets", "*", "versions", "*", "tablesets", "*", "tables", "*"}, accessTableset, (*gateway).describeTable},
+	{[]string{"v1", "datasets", "*", "versions", "*", "tablesets", "*", "access"}, accessChecked, (*gateway).checkAccess},
+	{[]string{"v1", "datasets", "*", "versions", "*", "tablesets", "*", "data"}, accessChecked, (*gateway).tidyset},
+}
+
+// matchRoute returns the handler and parameters for the escaped path.
+func matchRoute(path string) (gatewayRoute, []string, bool) {
+	var segments []string
+	for _, s := range strings.Split(strings.Trim(path, "/"), "/") {
+		u, err := url.PathUnescape(s)
+		if err != nil {
+			return gatewayRoute{}, nil, false
+		}
+		segments = append(segments, u)
+	}
+	for _, route := range gatewayRoutes {
+		if len(route.pattern) != len(segments) {
+			continue
+		}
+		var params []string
+		matched := true
+		for i, p := range route.pattern {
+			if p == "*" {
+				params = append(params, segments[i])
+			} else if p != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return route, params, true
+		}
+	}
+	return gatewayRoute{}, nil, false
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	caller, err := g.opts.Authenticate(r)
+	if g.opts.Log != nil {
+		if err != nil {
+			fmt.Fprintf(g.opts.Log, "%s %s unauthenticated: %v\n", r.Method, r.URL.RequestURI(), err)
+		} else {
+			fmt.Fprintf(g.opts.Log, "%s %s caller=%q\n", r.Method, r.URL.RequestURI(), caller)
+		}
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	route, params, ok := matchRoute(r.URL.EscapedPath())
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("no such endpoint: %s", r.URL.Path))
+		return
+	}
+	err = g.authorizeRoute(route.access, caller, params)
+	if err == nil {
+		err = route.handle(g, w, r, caller, params)
+	}
+	if err != nil {
+		code := http.StatusInternalServerError
+		if he, ok := err.(httpError); ok {
+			code = he.code
+		}
+		writeJSONError(w, code, err)
+	}
+}
+
+func writeJSONResponse(w http.ResponseWriter, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// queryList returns the comma-separated list in the query parameter name.
+func queryList(r *http.Request, name string) []string {
+	if v := r.URL.Query().Get(name); v != "" {
+		return strings.Split(v, ",")
+	}
+	return nil
+}
+
+func (g *gateway) listDatasets(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	all, err := g.client.ListDatasets(g.ctx)
+	if err != nil {
+		return err
+	}
+	datasets := []string{}
+	for _, dataset := range all {
+		switch err := g.authorizeDataset(caller, dataset); {
+		case err == nil:
+			datasets = append(datasets, dataset)
+		case !isForbidden(err):
+			return err
+		}
+	}
+	return writeJSONResponse(w, map[string]interface{}{"datasets": datasets})
+}
+
+func (g *gateway) describeDataset(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	description, err := g.client.DescribeDataset(g.ctx, p[0])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, map[string]string{"dataset": p[0], "description": description})
+}
+
+func (g *gateway) listVersions(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	req := VersionsRequest{Dataset: p[0], MinState: lowestState()}
+	if s := r.URL.Query().Get("min_state"); s != "" {
+		state, err := vdl.StateFromString(s)
+		if err != nil {
+			return httpError{http.StatusBadRequest, fmt.Errorf("couldn't parse min_state %v: %v", s, err)}
+		}
+		req.MinState = state
+	}
+	if s := r.URL.Query().Get("with_alias"); s != "" {
+		withAlias, err := strconv.ParseBool(s)
+		if err != nil {
+			return httpError{http.StatusBadRequest, fmt.Errorf("couldn't parse with_alias %v: %v", s, err)}
+		}
+		req.WithAlias = withAlias
+	}
+	req.Sort = r.URL.Query().Get("sort")
+	if s := r.URL.Query().Get("latest_per_state"); s != "" {
+		latest, err := strconv.ParseBool(s)
+		if err != nil {
+			return httpError{http.StatusBadRequest, fmt.Errorf("couldn't parse latest_per_state %v: %v", s, err)}
+		}
+		req.LatestPerState = latest
+	}
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"since", &req.Since}, {"until", &req.Until}} {
+		if s := r.URL.Query().Get(p.name); s != "" {
+			t, err := ParseTime(s)
+			if err != nil {
+				return httpError{http.StatusBadRequest, err}
+			}
+			*p.t = t
+		}
+	}
+	for _, p := range []struct {
+		name string
+		n    *int
+	}{{"offset", &req.Offset}, {"limit", &req.Limit}} {
+		if s := r.URL.Query().Get(p.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return httpError{http.StatusBadRequest, fmt.Errorf("couldn't parse %s %v", p.name, s)}
+			}
+			*p.n = n
+		}
+	}
+	all, err := g.client.ListVersions(g.ctx, req)
+	if err != nil {
+		return err
+	}
+	versions := []VersionListing{}
+	for _, v := range all {
+		switch err := g.authorizeVersion(caller, p[0], v.Version); {
+		case err == nil:
+			versions = append(versions, v)
+		case !isForbidden(err):
+			return err
+		}
+	}
+	return writeJSONResponse(w, map[string]interface{}{"versions": versions})
+}
+
+func (g *gateway) describeVersion(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	description, err := g.client.DescribeVersion(g.ctx, p[0], p[1])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, map[string]string{"dataset": p[0], "version": p[1], "description": description})
+}
+
+func (g *gateway) resolve(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	resolved, err := g.client.ResolveVersion(g.ctx, p[0], p[1])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, map[string]string{"dataset": p[0], "version": p[1], "resolved": resolved})
+}
+
+func (g *gateway) listAliases(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	aliases, err := g.client.ListAliases(g.ctx, p[0], p[1])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, map[string]interface{}{"aliases": aliases})
+}
+
+func (g *gateway) listSnapshots(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	snapshots, err := g.client.ListSnapshots(g.ctx, p[0], p[1])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, map[string]interface{}{"snapshots": snapshots})
+}
+
+func (g *gateway) listFilters(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	filters, err := g.client.ListFilters(g.ctx, p[0], p[1])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, map[string]interface{}{"filters": filters})
+}
+
+func (g *gateway) describeFilter(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	filter, err := g.client.DescribeFilter(g.ctx, p[0], p[1], p[2])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, filter)
+}
+
+func (g *gateway) describeColumn(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	column, err := g.client.DescribeColumn(g.ctx, p[0], p[1], p[2], p[3])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, column)
+}
+
+func (g *gateway) listTablesets(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	tablesets, err := g.client.ListTablesets(g.ctx, p[0], p[1])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, map[string]interface{}{"tablesets": tablesets})
+}
+
+func (g *gateway) describeTableset(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	description, err := g.client.DescribeTableset(g.ctx, p[0], p[1], p[2])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, map[string]string{"tableset": p[2], "description": description})
+}
+
+func (g *gateway) listTables(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	tables, err := g.client.ListTables(g.ctx, p[0], p[1], p[2])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, map[string]interface{}{"tables": tables})
+}
+
+func (g *gateway) describeTable(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	table, err := g.client.DescribeTable(g.ctx, p[0], p[1], p[2], p[3])
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, table)
+}
+
+// accessRequest builds the access request of the caller for the tableset
+// in p.
+func accessRequest(r *http.Request, caller string, p []string) AccessRequest {
+	return AccessRequest{
+		Identity: caller,
+		Dataset:  p[0],
+		Version:  p[1],
+		Tableset: p[2],
+		Filters:  queryList(r, "filters"),
+	}
+}
+
+// authorize returns a forbidden error unless req.Identity may fetch the
+// requested data.
+func (g *gateway) authorize(req AccessRequest) error {
+	if err := g.client.CheckAccess(g.ctx, req); err != nil {
+		return httpError{http.StatusForbidden, err}
+	}
+	return nil
+}
+
+// isForbidden reports whether err is a forbidden error from authorize.
+func isForbidden(err error) bool {
+	he, ok := err.(httpError)
+	return ok && he.code == http.StatusForbidden
+}
+
+// authorizeVersion returns a forbidden error unless caller may fetch some
+// tableset of the version without filters.
+func (g *gateway) authorizeVersion(caller, dataset, version string) error {
+	tablesets, err := g.client.ListTablesets(g.ctx, dataset, version)
+	if err != nil {
+		return err
+	}
+	denied := fmt.Errorf("%s %s has no tablesets", dataset, version)
+	for _, tableset := range tablesets {
+		err := g.authorize(AccessRequest{Identity: caller, Dataset: dataset, Version: version, Tableset: tableset})
+		if err == nil {
+			return nil
+		}
+		denied = err
+	}
+	if isForbidden(denied) {
+		return denied
+	}
+	return httpError{http.StatusForbidden, denied}
+}
+
+// authorizeDataset returns a forbidden error unless caller may fetch some
+// tableset of some version of the dataset without filters.
+func (g *gateway) authorizeDataset(caller, dataset string) error {
+	versions, err := g.client.ListVersions(g.ctx, VersionsRequest{Dataset: dataset, MinState: lowestState(), Sort: SortNatural})
+	if err != nil {
+		return err
+	}
+	// Newer versions are the likeliest to be readable.
+	for i := len(versions) - 1; i >= 0; i-- {
+		if err := g.authorizeVersion(caller, dataset, versions[i].Version); !isForbidden(err) {
+			return err
+		}
+	}
+	return httpError{http.StatusForbidden, fmt.Errorf("%s may not fetch any version of %s", caller, dataset)}
+}
+
+// authorizeRoute checks the access required by a route to the dataset,
+// version or tableset in p.
+func (g *gateway) authorizeRoute(access gatewayAccess, caller string, p []string) error {
+	switch access {
+	case accessDataset:
+		return g.authorizeDataset(caller, p[0])
+	case accessVersion:
+		return g.authorizeVersion(caller, p[0], p[1])
+	case accessTableset:
+		return g.authorize(AccessRequest{Identity: caller, Dataset: p[0], Version: p[1], Tableset: p[2]})
+	}
+	return nil
+}
+
+func (g *gateway) checkAccess(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	req := accessRequest(r, caller, p)
+	result := map[string]interface{}{"request": req, "allowed": true}
+	if err := g.client.CheckAccess(g.ctx, req); err != nil {
+		result["allowed"] = false
+		result["error"] = err.Error()
+	}
+	return writeJSONResponse(w, result)
+}
+
+// serveFile streams the fetched file as the response body.
+func serveFile(w http.ResponseWriter, fetched Fetched) error {
+	f, err := os.Open(fetched.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(fetched.Path)))
+	w.Header().Set(VersionHeader, fetched.Version)
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (g *gateway) tidyset(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	// Resolve an alias once, so that the version fetched is the one whose
+	// access was checked even if the alias moves in between.
+	version, err := g.client.ResolveVersion(g.ctx, p[0], p[1])
+	if err != nil {
+		return err
+	}
+	req := accessRequest(r, caller, p)
+	req.Version = version
+	if err := g.authorize(req); err != nil {
+		return err
+	}
+	fetched, err := g.client.Tidyset(g.ctx, TidysetRequest{
+		Dataset:     p[0],
+		Version:     version,
+		Tableset:    p[2],
+		Filters:     req.Filters,
+		Materialize: queryList(r, "materialize"),
+	})
+	if err != nil {
+		return err
+	}
+	return serveFile(w, fetched)
+}
+
+func (g *gateway) preprocessed(w http.ResponseWriter, r *http.Request, caller string, p []string) error {
+	version, err := g.client.ResolveVersion(g.ctx, p[0], p[1])
+	if err != nil {
+		return err
+	}
+	// Preprocessed data is derived from the whole version, so the caller
+	// must be allowed to fetch every one of its tablesets, unfiltered.
+	tablesets, err := g.client.ListTablesets(g.ctx, p[0], version)
+	if err != nil {
+		return err
+	}
+	if len(tablesets) == 0 {
+		return httpError{http.StatusForbidden, fmt.Errorf("%s %s has no tablesets to check access to", p[0], version)}
+	}
+	for _, tableset := range tablesets {
+		if err := g.authorize(AccessRequest{Identity: caller, Dataset: p[0], Version: version, Tableset: tableset}); err != nil {
+			return err
+		}
+	}
+	fetched, err := g.client.PreprocessedData(g.ctx, PreprocessedRequest{Dataset: p[0], Version: version})
+	if err != nil {
+		return err
+	}
+	return serveFile(w, fetched)
+}
@@ -0,0 +1,107 @@
+package tidydata
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	tidy "grail.com/tidy/vanadium/client"
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+)
+
+// gatewayServer is a tidy server with two datasets whose versions each
+// have the tablesets clinical and genomic, and which grants access to the
+// tablesets in allowed, keyed by "identity dataset version tableset".
+type gatewayServer struct {
+	tidy.Client
+	allowed map[string]bool
+}
+
+func (s *gatewayServer) ListDatasets() ([]string, error) {
+	return []string{"ccga", "pcr"}, nil
+}
+
+func (s *gatewayServer) ListAliasedVersions(dataset string) ([]vdl.AliasedVersion, error) {
+	return nil, nil
+}
+
+func (s *gatewayServer) ListVersionsAt(dataset string, state vdl.State) ([]vdl.VersionState, error) {
+	return []vdl.VersionState{{Version: "v1", State: vdl.StatePublished}, {Version: "v2", State: vdl.StatePublished}}, nil
+}
+
+func (s *gatewayServer) ListTablesets(dataset, version string) ([]string, error) {
+	return []string{"clinical", "genomic"}, nil
+}
+
+func (s *gatewayServer) CheckAccess(identity, dataset, version, tableset string, filters []string) error {
+	if !s.allowed[identity+" "+dataset+" "+version+" "+tableset] {
+		return errors.New("access denied")
+	}
+	return nil
+}
+
+func (s *gatewayServer) DescribeDataset(dataset string) (string, error) {
+	return "a dataset", nil
+}
+
+func (s *gatewayServer) DescribeVersion(dataset, version string) (string, error) {
+	return "a version", nil
+}
+
+func (s *gatewayServer) DescribeTableset(dataset, version, tableset string) (string, error) {
+	return "a tableset", nil
+}
+
+func TestGatewayMetadataAccess(t *testing.T) {
+	server := &gatewayServer{allowed: map[string]bool{"alice ccga v2 clinical": true}}
+	handler, err := NewGateway(nil, NewClientFrom("", server), GatewayOptions{
+		Authenticate: func(r *http.Request) (string, error) { return r.Header.Get("X-Caller"), nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(caller, path string) (int, map[string]interface{}) {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("X-Caller", caller)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		return w.Code, body
+	}
+
+	for _, test := range []struct {
+		caller, path string
+		code         int
+	}{
+		{"alice", "/v1/datasets/ccga", http.StatusOK},
+		{"alice", "/v1/datasets/pcr", http.StatusForbidden},
+		{"alice", "/v1/datasets/ccga/versions/v2", http.StatusOK},
+		{"alice", "/v1/datasets/ccga/versions/v1", http.StatusForbidden},
+		{"alice", "/v1/datasets/ccga/versions/v2/tablesets/clinical", http.StatusOK},
+		{"alice", "/v1/datasets/ccga/versions/v2/tablesets/genomic", http.StatusForbidden},
+		{"bob", "/v1/datasets/ccga/versions/v2", http.StatusForbidden},
+	} {
+		if code, body := get(test.caller, test.path); code != test.code {
+			t.Errorf("GET %s as %s: status %d (%v), want %d", test.path, test.caller, code, body, test.code)
+		}
+	}
+
+	// Listings omit what the caller may not see.
+	if _, body := get("alice", "/v1/datasets"); !reflect.DeepEqual(body["datasets"], []interface{}{"ccga"}) {
+		t.Errorf("datasets listed for alice: %v, want [ccga]", body["datasets"])
+	}
+	if _, body := get("bob", "/v1/datasets"); !reflect.DeepEqual(body["datasets"], []interface{}{}) {
+		t.Errorf("datasets listed for bob: %v, want none", body["datasets"])
+	}
+	_, body := get("alice", "/v1/datasets/ccga/versions")
+	versions, _ := body["versions"].([]interface{})
+	if len(versions) != 1 || versions[0].(map[string]interface{})["version"] != "v2" {
+		t.Errorf("versions listed for alice: %v, want only v2", body["versions"])
+	}
+}
@@ -0,0 +1,92 @@
+package tidydata
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MinTokenKeySize is the minimum size of the key that signs gateway tokens.
+const MinTokenKeySize = 32
+
+// CertificateIdentity authenticates gateway callers by their TLS client
+// certificate, which the server must verify against its client CAs. The
+// caller's blessing string is the subject common name of the certificate.
+func CertificateIdentity(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", errors.New("no verified client certificate")
+	}
+	identity := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if identity == "" {
+		return "", errors.New("client certificate has no common name")
+	}
+	return identity, nil
+}
+
+// gatewayToken is the signed payload of a gateway token.
+type gatewayToken struct {
+	Identity string `json:"identity"`
+	Expires  int64  `json:"exp"`
+}
+
+// tokenSignature returns the HMAC-SHA256 of payload under key.
+func tokenSignature(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// SignGatewayToken returns a bearer token, accepted by TokenIdentity with
+// the same key until expires, that authenticates the holder as identity.
+func SignGatewayToken(key []byte, identity string, expires time.Time) (string, error) {
+	if len(key) < MinTokenKeySize {
+		return "", fmt.Errorf("token key must be at least %d bytes", MinTokenKeySize)
+	}
+	data, err := json.Marshal(gatewayToken{Identity: identity, Expires: expires.Unix()})
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(tokenSignature(key, payload)), nil
+}
+
+// TokenIdentity returns an authenticator of gateway callers that carry a
+// token signed by SignGatewayToken with key in an "Authorization: Bearer"
+// header. The caller's blessing string is the identity in the token.
+func TokenIdentity(key []byte) (func(r *http.Request) (string, error), error) {
+	if len(key) < MinTokenKeySize {
+		return nil, fmt.Errorf("token key must be at least %d bytes", MinTokenKeySize)
+	}
+	return func(r *http.Request) (string, error) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return "", errors.New("missing bearer token")
+		}
+		parts := strings.Split(strings.TrimPrefix(auth, "Bearer "), ".")
+		if len(parts) != 2 {
+			return "", errors.New("malformed bearer token")
+		}
+		sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil || !hmac.Equal(sig, tokenSignature(key, parts[0])) {
+			return "", errors.New("invalid bearer token signature")
+		}
+		data, err := base64.RawURLEncoding.DecodeString(parts[0])
+		if err != nil {
+			return "", errors.New("malformed bearer token")
+		}
+		var t gatewayToken
+		if err := json.Unmarshal(data, &t); err != nil || t.Identity == "" {
+			return "", errors.New("malformed bearer token")
+		}
+		if time.Now().Unix() >= t.Expires {
+			return "", errors.New("expired bearer token")
+		}
+		return t.Identity, nil
+	}, nil
+}
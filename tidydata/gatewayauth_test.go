@@ -0,0 +1,72 @@
+package tidydata
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenIdentity(t *testing.T) {
+	key := bytes.Repeat([]byte("k"), MinTokenKeySize)
+	auth, err := TokenIdentity(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(header string) (string, error) {
+		r, _ := http.NewRequest("GET", "/v1/datasets", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		return auth(r)
+	}
+	token, err := SignGatewayToken(key, "dev.v.io:u:alice@grail.com", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		if id, err := call("Bearer " + token); err != nil || id != "dev.v.io:u:alice@grail.com" {
+			t.Errorf("got %q, %v", id, err)
+		}
+	})
+	t.Run("missing", func(t *testing.T) {
+		if _, err := call(""); err == nil {
+			t.Error("a request without a token was authenticated")
+		}
+		if _, err := call("Basic " + token); err == nil {
+			t.Error("a request with basic authorization was authenticated")
+		}
+	})
+	t.Run("other key", func(t *testing.T) {
+		other, _ := SignGatewayToken(bytes.Repeat([]byte("o"), MinTokenKeySize), "dev.v.io:u:alice@grail.com", time.Now().Add(time.Hour))
+		if _, err := call("Bearer " + other); err == nil {
+			t.Error("a token signed with another key was accepted")
+		}
+	})
+	t.Run("tampered", func(t *testing.T) {
+		// Graft the payload of a token for another identity onto the
+		// signature of alice's.
+		forged, _ := SignGatewayToken(key, "dev.v.io:u:mallory@grail.com", time.Now().Add(time.Hour))
+		payload := forged[:strings.Index(forged, ".")]
+		signature := token[strings.Index(token, "."):]
+		if id, err := call("Bearer " + payload + signature); err == nil {
+			t.Errorf("a tampered token was accepted as %q", id)
+		}
+	})
+	t.Run("expired", func(t *testing.T) {
+		expired, _ := SignGatewayToken(key, "dev.v.io:u:alice@grail.com", time.Now().Add(-time.Second))
+		if _, err := call("Bearer " + expired); err == nil {
+			t.Error("an expired token was accepted")
+		}
+	})
+	t.Run("short key", func(t *testing.T) {
+		if _, err := SignGatewayToken(key[1:], "x", time.Now()); err == nil {
+			t.Error("SignGatewayToken accepted a short key")
+		}
+		if _, err := TokenIdentity(key[1:]); err == nil {
+			t.Error("TokenIdentity accepted a short key")
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package tidydata
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// StateChange records that a version was observed in a state.
+type StateChange struct {
+	State string    `json:"state"`
+	Since time.Time `json:"since"`
+}
+
+// VersionRecord is the locally observed history of a version. The tidy
+// server does not record when versions are created or change state, so
+// the times are those at which this client first observed them and are
+// upper bounds on the actual times.
+type VersionRecord struct {
+	FirstSeen time.Time     `json:"first_seen"`
+	States    []StateChange `json:"states"`
+}
+
+// StateAt returns the state the version was observed in at t, or false if
+// it had not been observed by then.
+func (r *VersionRecord) StateAt(t time.Time) (string, bool) {
+	state, ok := "", false
+	for _, s := range r.States {
+		if s.Since.After(t) {
+			break
+		}
+		state, ok = s.State, true
+	}
+	return state, ok
+}
+
+// VersionHistory is a local record of the versions of datasets observed
+// by the client, stored as a JSON file.
+type VersionHistory struct {
+	// Path is the file the history is stored in.
+	Path string `json:"-"`
+	// Datasets maps dataset names to versions to their records.
+	Datasets map[string]map[string]*VersionRecord `json:"datasets"`
+	// Aliases maps dataset names to aliases to the versions they were
+	// observed to point to, oldest first.
+	Aliases map[string]map[string][]AliasChange `json:"aliases,omitempty"`
+}
+
+// AliasChange records that an alias was observed to point to a version.
+// An empty Version records that the alias was observed to be removed.
+type AliasChange struct {
+	Version string    `json:"version"`
+	Since   time.Time `json:"since"`
+}
+
+// LoadVersionHistory reads the version history at path. A missing file is
+// an empty history.
+func LoadVersionHistory(path string) (*VersionHistory, error) {
+	h := &VersionHistory{Path: path}
+	if err := readJSONFile(path, h); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if h.Datasets == nil {
+		h.Datasets = map[string]map[string]*VersionRecord{}
+	}
+	if h.Aliases == nil {
+		h.Aliases = map[string]map[string][]AliasChange{}
+	}
+	return h, nil
+}
+
+// Save writes the history to its file.
+func (h *VersionHistory) Save() error {
+	return writeJSONFile(h.Path, h)
+}
+
+// Record returns the record of a version, or nil if it has not been
+// observed.
+func (h *VersionHistory) Record(dataset, version string) *VersionRecord {
+	return h.Datasets[dataset][version]
+}
+
+// Observe records that version was in state at now, and reports whether
+// the history changed.
+func (h *VersionHistory) Observe(dataset, version, state string, now time.Time) bool {
+	versions := h.Datasets[dataset]
+	if versions == nil {
+		versions = map[string]*VersionRecord{}
+		h.Datasets[dataset] = versions
+	}
+	r := versions[version]
+	if r == nil {
+		r = &VersionRecord{FirstSeen: now}
+		versions[version] = r
+	}
+	if n := len(r.States); n > 0 && r.States[n-1].State == state {
+		return false
+	}
+	r.States = append(r.States, StateChange{State: state, Since: now})
+	return true
+}
+
+// ObserveAlias records that alias pointed to version at now, and reports
+// whether the history changed.
+func (h *VersionHistory) ObserveAlias(dataset, alias, version string, now time.Time) bool {
+	aliases := h.Aliases[dataset]
+	if aliases == nil {
+		aliases = map[string][]AliasChange{}
+		h.Aliases[dataset] = aliases
+	}
+	changes := aliases[alias]
+	if n := len(changes); n > 0 && changes[n-1].Version == version {
+		return false
+	}
+	if len(changes) == 0 && version == "" {
+		return false
+	}
+	aliases[alias] = append(changes, AliasChange{Version: version, Since: now})
+	return true
+}
+
+// ObserveAliases records the complete set of aliases of a dataset, mapping
+// aliases to versions, at now. Previously observed aliases missing from
+// the set are recorded as removed. It reports whether the history changed.
+func (h *VersionHistory) ObserveAliases(dataset string, aliases map[string]string, now time.Time) bool {
+	changed := false
+	for alias, version := range aliases {
+		if h.ObserveAlias(dataset, alias, version, now) {
+			changed = true
+		}
+	}
+	for alias := range h.Aliases[dataset] {
+		if _, ok := aliases[alias]; !ok && h.ObserveAlias(dataset, alias, "", now) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// AliasAt returns the last change of alias observed at or before t. It
+// returns false if the alias had not been observed by t.
+func (h *VersionHistory) AliasAt(dataset, alias string, t time.Time) (AliasChange, bool) {
+	var last AliasChange
+	ok := false
+	for _, c := range h.Aliases[dataset][alias] {
+		if c.Since.After(t) {
+			break
+		}
+		last, ok = c, true
+	}
+	return last, ok
+}
+
+// ParseTime parses a time given as RFC 3339 or as a date, which is taken
+// to be midnight UTC.
+func ParseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("couldn't parse time %q: must be RFC 3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
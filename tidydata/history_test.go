@@ -0,0 +1,47 @@
+package tidydata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	for _, s := range []string{"2024-03-01", "2024-03-01T00:00:00Z", "2024-02-29T16:00:00-08:00"} {
+		got, err := ParseTime(s)
+		if err != nil {
+			t.Errorf("ParseTime(%q): %v", s, err)
+			continue
+		}
+		if want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", s, got, want)
+		}
+	}
+	for _, s := range []string{"", "2024/03/01", "March 1"} {
+		if _, err := ParseTime(s); err == nil {
+			t.Errorf("ParseTime(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestVersionRecordStateAt(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	r := &VersionRecord{FirstSeen: t0, States: []StateChange{
+		{State: "generating", Since: t0},
+		{State: "tested", Since: t0.Add(time.Hour)},
+		{State: "published", Since: t0.Add(2 * time.Hour)},
+	}}
+	if state, ok := r.StateAt(t0.Add(-time.Nanosecond)); ok {
+		t.Errorf("StateAt before the version was seen = %q, want none", state)
+	}
+	for offset, want := range map[time.Duration]string{
+		0:                       "generating",
+		time.Hour - time.Second: "generating",
+		time.Hour:               "tested",
+		90 * time.Minute:        "tested",
+		48 * time.Hour:          "published",
+	} {
+		if state, ok := r.StateAt(t0.Add(offset)); !ok || state != want {
+			t.Errorf("StateAt(+%v) = %q, %v, want %q", offset, state, ok, want)
+		}
+	}
+}
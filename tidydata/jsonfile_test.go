@@ -0,0 +1,73 @@
+package tidydata
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJSONFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	var got map[string]int
+	if err := readJSONFile(path, &got); !os.IsNotExist(err) {
+		t.Fatalf("readJSONFile of missing file: got %v, want a not-exist error", err)
+	}
+	for _, want := range []map[string]int{{"a": 1}, {"b": 2}} {
+		if err := writeJSONFile(path, want); err != nil {
+			t.Fatal(err)
+		}
+		got = nil
+		if err := readJSONFile(path, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("readJSONFile: got %v, want %v", got, want)
+		}
+	}
+	// The temporary file written alongside is renamed into place.
+	if infos, err := ioutil.ReadDir(dir); err != nil || len(infos) != 1 {
+		t.Errorf("after writeJSONFile the directory has %d files (%v), want 1", len(infos), err)
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.jsonl")
+
+	var lines []string
+	collect := func(line []byte) error {
+		var s string
+		if err := json.Unmarshal(line, &s); err != nil {
+			return err
+		}
+		lines = append(lines, s)
+		return nil
+	}
+	if err := readJSONLines(path, collect); err != nil || lines != nil {
+		t.Fatalf("readJSONLines of missing file: got %q, %v, want no lines", lines, err)
+	}
+	for _, s := range []string{"first", "second\nline", "third"} {
+		if err := appendJSONLine(path, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := readJSONLines(path, collect); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first", "second\nline", "third"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("readJSONLines: got %q, want %q", lines, want)
+	}
+}
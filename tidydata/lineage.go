@@ -0,0 +1,317 @@
+package tidydata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"v.io/v23/context"
+)
+
+// Kinds of lineage nodes.
+const (
+	LineageVersion      = "version"
+	LineageSnapshot     = "snapshot"
+	LineagePreprocessed = "preprocessed"
+	LineageRun          = "run"
+)
+
+// LineageNode is a node of the graph of inputs that produced a dataset
+// version.
+type LineageNode struct {
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+	// Dataset and Version are set for version nodes.
+	Dataset string `json:"dataset,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Error records why the inputs of an upstream version could not be
+	// found.
+	Error string `json:"error,omitempty"`
+	// Inferred is set on version nodes whose inputs were parsed from the
+	// free-form lines of a description that has no lineage record.
+	Inferred bool           `json:"inferred,omitempty"`
+	Inputs   []*LineageNode `json:"inputs,omitempty"`
+}
+
+// LineageRecord declares the inputs that produced a dataset version. It is
+// kept on the server as a line of the version description, such as
+//
+//	lineage: {"upstream":[{"dataset":"ccga","version":"v3"}],"runs":["8412"]}
+//
+// so that every client sees it; see SetLineage.
+type LineageRecord struct {
+	Upstream     []LineageRef `json:"upstream,omitempty"`
+	Runs         []string     `json:"runs,omitempty"`
+	Preprocessed []string     `json:"preprocessed,omitempty"`
+}
+
+// LineageRef identifies an upstream dataset version.
+type LineageRef struct {
+	Dataset string `json:"dataset"`
+	Version string `json:"version"`
+}
+
+// String returns the reference as dataset@version.
+func (r LineageRef) String() string {
+	return r.Dataset + "@" + r.Version
+}
+
+// ParseLineageRef parses an upstream reference of the form dataset@version.
+func ParseLineageRef(ref string) (LineageRef, error) {
+	i := strings.Index(ref, "@")
+	if i <= 0 || i == len(ref)-1 {
+		return LineageRef{}, fmt.Errorf("upstream %q is not of the form dataset@version", ref)
+	}
+	return LineageRef{Dataset: ref[:i], Version: ref[i+1:]}, nil
+}
+
+var lineageLine = regexp.MustCompile(`^\s*lineage\s*:(.*)$`)
+
+// ParseLineageRecord returns the lineage record in a version description,
+// or nil if there is none.
+func ParseLineageRecord(description string) (*LineageRecord, error) {
+	for _, line := range strings.Split(description, "\n") {
+		m := lineageLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		r := &LineageRecord{}
+		if err := json.Unmarshal([]byte(m[1]), r); err != nil {
+			return nil, fmt.Errorf("couldn't parse lineage %q: %v", line, err)
+		}
+		return r, nil
+	}
+	return nil, nil
+}
+
+// String formats r as a description line.
+func (r *LineageRecord) String() string {
+	data, _ := json.Marshal(r)
+	return "lineage: " + string(data)
+}
+
+// SetLineage records the inputs of a version in its description, replacing
+// any earlier lineage record.
+func (c *Client) SetLineage(ctx *context.T, dataset, version string, r LineageRecord) error {
+	for _, u := range r.Upstream {
+		if u.Dataset == "" || u.Version == "" {
+			return fmt.Errorf("upstream %q is not of the form dataset@version", u.String())
+		}
+	}
+	return c.replaceDescriptionLine(ctx, dataset, version, lineageLine, r.String())
+}
+
+// lineageField matches the free-form lines of a version description that
+// declare inputs, such as "upstream: ccga@v3, pcr@2024_01" or "pipeline
+// run: 8412". They are only read from descriptions without a lineage
+// record.
+var lineageField = regexp.MustCompile(`(?i)^\s*[-*]?\s*(upstream|inputs?|run|pipeline[ _]run|run[ _]id|preprocessed)s?\s*[:=]\s*(.+?)\s*$`)
+
+// parseLineage extracts the inputs declared in a version description.
+func parseLineage(description string) (upstream, runs, preprocessed []string) {
+	for _, line := range strings.Split(description, "\n") {
+		m := lineageField.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		var values []string
+		for _, v := range strings.Split(m[2], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		switch key := strings.ToLower(m[1]); {
+		case key == "preprocessed":
+			preprocessed = append(preprocessed, values...)
+		case strings.HasPrefix(key, "input") || key == "upstream":
+			upstream = append(upstream, values...)
+		default:
+			runs = append(runs, values...)
+		}
+	}
+	return
+}
+
+// splitDatasetVersion splits an upstream reference of the form
+// dataset@version, dataset/version or "dataset version".
+func splitDatasetVersion(ref string) (string, string, bool) {
+	for _, sep := range []string{"@", "/", " "} {
+		if i := strings.Index(ref, sep); i > 0 {
+			return ref[:i], strings.TrimSpace(ref[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// LineageRequest identifies the version whose lineage is built.
+type LineageRequest struct {
+	Dataset string
+	Version string
+	// Depth limits how many levels of upstream versions are followed. A
+	// zero Depth follows only the version's own inputs.
+	Depth int
+}
+
+// Lineage builds the graph of inputs that produced a dataset version: its
+// snapshots, as listed by the server, and the upstream versions, pipeline
+// runs and preprocessed data in its lineage record. Versions without one
+// fall back to free-form description lines such as "upstream:
+// dataset@version", "pipeline run: id" and "preprocessed: name", and are
+// marked Inferred. Upstream versions are followed recursively up to
+// req.Depth levels.
+func (c *Client) Lineage(ctx *context.T, req LineageRequest) (*LineageNode, error) {
+	return c.lineage(ctx, req.Dataset, req.Version, req.Depth, map[string]bool{})
+}
+
+func (c *Client) lineage(ctx *context.T, dataset, version string, depth int, seen map[string]bool) (*LineageNode, error) {
+	node := &LineageNode{Kind: LineageVersion, Label: dataset + "@" + version, Dataset: dataset, Version: version}
+	seen[node.Label] = true
+	snapshots, err := c.ListSnapshots(ctx, dataset, version)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(snapshots)
+	for _, s := range snapshots {
+		node.Inputs = append(node.Inputs, &LineageNode{Kind: LineageSnapshot, Label: s})
+	}
+	description, err := c.DescribeVersion(ctx, dataset, version)
+	if err != nil {
+		return nil, err
+	}
+	var upstream, runs, preprocessed []string
+	record, err := ParseLineageRecord(description)
+	switch {
+	case err != nil:
+		return nil, err
+	case record != nil:
+		for _, u := range record.Upstream {
+			upstream = append(upstream, u.String())
+		}
+		runs, preprocessed = record.Runs, record.Preprocessed
+	default:
+		upstream, runs, preprocessed = parseLineage(description)
+		node.Inferred = len(upstream)+len(runs)+len(preprocessed) > 0
+	}
+	for _, p := range preprocessed {
+		node.Inputs = append(node.Inputs, &LineageNode{Kind: LineagePreprocessed, Label: p})
+	}
+	for _, r := range runs {
+		node.Inputs = append(node.Inputs, &LineageNode{Kind: LineageRun, Label: r})
+	}
+	for _, ref := range upstream {
+		d, v, ok := splitDatasetVersion(ref)
+		if !ok {
+			node.Inputs = append(node.Inputs, &LineageNode{Kind: LineageVersion, Label: ref, Error: "not of the form dataset@version"})
+			continue
+		}
+		label := d + "@" + v
+		if depth <= 0 || seen[label] {
+			node.Inputs = append(node.Inputs, &LineageNode{Kind: LineageVersion, Label: label, Dataset: d, Version: v})
+			continue
+		}
+		up, err := c.lineage(ctx, d, v, depth-1, seen)
+		if err != nil {
+			up = &LineageNode{Kind: LineageVersion, Label: label, Dataset: d, Version: v, Error: err.Error()}
+		}
+		node.Inputs = append(node.Inputs, up)
+	}
+	return node, nil
+}
+
+// WriteLineage writes a lineage graph as an indented tree ("tree"), a
+// Graphviz digraph ("dot") or a Mermaid flowchart ("mermaid"). Edges point
+// from inputs to the versions they produced.
+func WriteLineage(w io.Writer, root *LineageNode, format string) error {
+	switch format {
+	case "tree":
+		fmt.Fprintln(w, nodeText(root))
+		writeTree(w, root.Inputs, "")
+		return nil
+	case "dot":
+		fmt.Fprintln(w, "digraph lineage {")
+		fmt.Fprintln(w, "  rankdir=LR;")
+		ids, edges := lineageGraph(root, func(id string, n *LineageNode) {
+			fmt.Fprintf(w, "  %s [label=%q, shape=%s];\n", id, n.Kind+"\n"+n.Label, dotShape(n.Kind))
+		})
+		for _, e := range edges {
+			fmt.Fprintf(w, "  %s -> %s;\n", ids[e[0]], ids[e[1]])
+		}
+		fmt.Fprintln(w, "}")
+		return nil
+	case "mermaid":
+		fmt.Fprintln(w, "flowchart LR")
+		ids, edges := lineageGraph(root, func(id string, n *LineageNode) {
+			fmt.Fprintf(w, "  %s[\"%s: %s\"]\n", id, n.Kind, strings.Replace(n.Label, `"`, "#quot;", -1))
+		})
+		for _, e := range edges {
+			fmt.Fprintf(w, "  %s --> %s\n", ids[e[0]], ids[e[1]])
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported lineage format %q: must be tree, dot, mermaid or json", format)
+}
+
+func nodeText(n *LineageNode) string {
+	s := n.Kind + " " + n.Label
+	if n.Inferred {
+		s += " [inputs inferred from description]"
+	}
+	if n.Error != "" {
+		s += " (" + n.Error + ")"
+	}
+	return s
+}
+
+func writeTree(w io.Writer, nodes []*LineageNode, prefix string) {
+	for i, n := range nodes {
+		branch, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprintln(w, prefix+branch+nodeText(n))
+		writeTree(w, n.Inputs, prefix+indent)
+	}
+}
+
+// lineageGraph assigns an id to each distinct node, by kind and label, of
+// the graph rooted at root, calling node for each the first time it is
+// seen, and returns the ids and the distinct edges from inputs to the
+// nodes they produced.
+func lineageGraph(root *LineageNode, node func(id string, n *LineageNode)) (map[string]string, [][2]string) {
+	ids := map[string]string{}
+	var edges [][2]string
+	seen := map[[2]string]bool{}
+	var walk func(n *LineageNode) string
+	walk = func(n *LineageNode) string {
+		key := n.Kind + "\x00" + n.Label
+		if _, ok := ids[key]; !ok {
+			ids[key] = fmt.Sprintf("n%d", len(ids))
+			node(ids[key], n)
+		}
+		for _, in := range n.Inputs {
+			e := [2]string{walk(in), key}
+			if !seen[e] {
+				seen[e] = true
+				edges = append(edges, e)
+			}
+		}
+		return key
+	}
+	walk(root)
+	return ids, edges
+}
+
+func dotShape(kind string) string {
+	switch kind {
+	case LineageSnapshot:
+		return "cylinder"
+	case LineageRun:
+		return "ellipse"
+	case LineagePreprocessed:
+		return "folder"
+	}
+	return "box"
+}
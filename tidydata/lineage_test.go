@@ -0,0 +1,73 @@
+package tidydata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLineage(t *testing.T) {
+	for name, test := range map[string]struct {
+		description                  string
+		upstream, runs, preprocessed []string
+	}{
+		"empty":          {"", nil, nil, nil},
+		"upstream list":  {"upstream: ccga@v3, pcr@2024_01", []string{"ccga@v3", "pcr@2024_01"}, nil, nil},
+		"bullets":        {"Inputs = ccga@v3\n- pipeline run: 8412\n* run_id=8413", []string{"ccga@v3"}, []string{"8412", "8413"}, nil},
+		"blank values":   {"Preprocessed: s3://bucket/a , ,s3://bucket/b", nil, nil, []string{"s3://bucket/a", "s3://bucket/b"}},
+		"prose mentions": {"free text mentioning upstream data", nil, nil, nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			upstream, runs, preprocessed := parseLineage(test.description)
+			if !reflect.DeepEqual(upstream, test.upstream) {
+				t.Errorf("upstream = %q, want %q", upstream, test.upstream)
+			}
+			if !reflect.DeepEqual(runs, test.runs) {
+				t.Errorf("runs = %q, want %q", runs, test.runs)
+			}
+			if !reflect.DeepEqual(preprocessed, test.preprocessed) {
+				t.Errorf("preprocessed = %q, want %q", preprocessed, test.preprocessed)
+			}
+		})
+	}
+}
+
+func TestParseLineageRef(t *testing.T) {
+	for ref, want := range map[string]LineageRef{
+		"ccga@v3":   {Dataset: "ccga", Version: "v3"},
+		"ccga@v3@x": {Dataset: "ccga", Version: "v3@x"},
+	} {
+		if got, err := ParseLineageRef(ref); err != nil || got != want {
+			t.Errorf("ParseLineageRef(%q) = %+v, %v, want %+v", ref, got, err, want)
+		}
+	}
+	for _, ref := range []string{"ccga", "@v3", "ccga@", ""} {
+		if got, err := ParseLineageRef(ref); err == nil {
+			t.Errorf("ParseLineageRef(%q) = %+v, want an error", ref, got)
+		}
+	}
+}
+
+func TestParseLineageRecord(t *testing.T) {
+	// A record written by SetLineage is read back from among the other
+	// lines of the description.
+	want := &LineageRecord{
+		Upstream:     []LineageRef{{Dataset: "ccga", Version: "v3"}},
+		Runs:         []string{"8412"},
+		Preprocessed: []string{"s3://bucket/a"},
+	}
+	description := "training set\n" + want.String() + "\ndeprecated: sunset=2025-06-30"
+	if got, err := ParseLineageRecord(description); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLineageRecord(%q) = %+v, %v, want %+v", description, got, err, want)
+	}
+
+	// Free-form lines are not a record.
+	for _, description := range []string{"", "upstream: ccga@v3"} {
+		if got, err := ParseLineageRecord(description); err != nil || got != nil {
+			t.Errorf("ParseLineageRecord(%q) = %+v, %v, want no record", description, got, err)
+		}
+	}
+
+	if _, err := ParseLineageRecord("lineage: {"); err == nil {
+		t.Error("ParseLineageRecord of a malformed record succeeded, want an error")
+	}
+}
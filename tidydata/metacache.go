@@ -0,0 +1,186 @@
+package tidydata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Kinds of cached metadata, each with its own TTL.
+const (
+	// MetaDatasets is the list of datasets.
+	MetaDatasets = "datasets"
+	// MetaVersions covers version listings, aliases and alias resolution,
+	// which change whenever versions are added, published or re-aliased.
+	MetaVersions = "versions"
+	// MetaStructure covers the tablesets, filters, tables and snapshots of
+	// versions, which rarely change once a version exists.
+	MetaStructure = "structure"
+	// MetaDescriptions covers the descriptions of datasets, versions,
+	// tablesets, filters, tables and columns.
+	MetaDescriptions = "descriptions"
+)
+
+// DefaultMetadataTTLs are the times for which cached metadata of each kind
+// is considered fresh.
+var DefaultMetadataTTLs = map[string]time.Duration{
+	MetaDatasets:     24 * time.Hour,
+	MetaVersions:     time.Hour,
+	MetaStructure:    7 * 24 * time.Hour,
+	MetaDescriptions: 24 * time.Hour,
+}
+
+// ErrNotCached is returned in offline mode for metadata, or data, that was
+// never fetched from the server.
+var ErrNotCached = errors.New("not in the offline cache")
+
+// CachedResponse describes a server response kept in a MetadataCache.
+type CachedResponse struct {
+	Kind string `json:"kind"`
+	// Key identifies the request, such as ["tables", dataset, version,
+	// tableset].
+	Key     []string        `json:"key"`
+	Fetched time.Time       `json:"fetched"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// String returns the request the response answers, such as "tables ccga v3
+// clinical".
+func (r CachedResponse) String() string {
+	return strings.Join(r.Key, " ")
+}
+
+// MetadataCache keeps the metadata responses of the server, one file per
+// request, so that list, describe and resolve requests can be answered
+// without it. Responses are always written through when online; offline,
+// they are served regardless of age, and those older than the TTL of their
+// kind are reported to Stale.
+type MetadataCache struct {
+	Dir string
+	// TTLs overrides DefaultMetadataTTLs for some kinds.
+	TTLs map[string]time.Duration
+	// Offline serves every request from the cache, and tidysets and
+	// preprocessed data from the files recorded in the client's fetch log.
+	Offline bool
+	// Stale, if set, is called for each response served offline that is
+	// older than its TTL.
+	Stale func(r CachedResponse, age, ttl time.Duration)
+}
+
+// SetMetadataCache makes the client cache metadata responses in m, and
+// serve them from it if m.Offline is set. A nil m disables caching.
+func (c *Client) SetMetadataCache(m *MetadataCache) {
+	c.cache = m
+}
+
+// offline reports whether the client serves requests without the server.
+func (c *Client) offline() bool {
+	return c.cache != nil && c.cache.Offline
+}
+
+// TTL returns the time for which metadata of the given kind is fresh.
+func (m *MetadataCache) TTL(kind string) time.Duration {
+	if ttl, ok := m.TTLs[kind]; ok {
+		return ttl
+	}
+	return DefaultMetadataTTLs[kind]
+}
+
+// path returns the file holding the response to key.
+func (m *MetadataCache) path(kind string, key []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return filepath.Join(m.Dir, kind, hex.EncodeToString(sum[:])+".json")
+}
+
+// store records v as the response to key.
+func (m *MetadataCache) store(kind string, key []string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	path := m.path(kind, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	return writeJSONFile(path, CachedResponse{Kind: kind, Key: key, Fetched: time.Now().UTC(), Value: value})
+}
+
+// load decodes the cached response to key into v, reporting it to m.Stale
+// if it is older than its TTL.
+func (m *MetadataCache) load(kind string, key []string, v interface{}) error {
+	var r CachedResponse
+	if err := readJSONFile(m.path(kind, key), &r); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", strings.Join(key, " "), ErrNotCached)
+		}
+		return err
+	}
+	m.served(r, m.TTL(kind))
+	return json.Unmarshal(r.Value, v)
+}
+
+// served reports r to m.Stale if it is older than ttl.
+func (m *MetadataCache) served(r CachedResponse, ttl time.Duration) {
+	if age := time.Since(r.Fetched); age > ttl && m.Stale != nil {
+		m.Stale(r, age, ttl)
+	}
+}
+
+// cached answers the request identified by key: from the client's metadata
+// cache if offline, and otherwise by calling fetch, which sets v, and
+// caching v. A failure to cache v is only reported as a warning.
+func (c *Client) cached(kind string, key []string, v interface{}, fetch func() error) error {
+	if c.cache == nil {
+		return fetch()
+	}
+	if c.cache.Offline {
+		return c.cache.load(kind, key, v)
+	}
+	if err := fetch(); err != nil {
+		return err
+	}
+	if err := c.cache.store(kind, key, v); err != nil {
+		c.warnf("couldn't cache %s: %v", strings.Join(key, " "), err)
+	}
+	return nil
+}
+
+// offlineFetch returns the most recent successful online fetch, recorded in
+// the client's fetch log, of data matching r whose file is still present. A
+// fetch of an alias older than the TTL of versions is reported to Stale,
+// since the alias may have moved since.
+func (c *Client) offlineFetch(r FetchRecord) (FetchRecord, error) {
+	key := []string{r.Kind, r.Dataset, r.Requested}
+	if r.Tableset != "" {
+		key = append(key, r.Tableset)
+	}
+	if c.fetchLog == nil {
+		return FetchRecord{}, fmt.Errorf("%s: %w: no fetch log", strings.Join(key, " "), ErrNotCached)
+	}
+	records, err := c.fetchLog.Records()
+	if err != nil {
+		return FetchRecord{}, err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		f := records[i]
+		if f.Error != "" || f.Offline || f.Kind != r.Kind || f.Dataset != r.Dataset || (f.Requested != r.Requested && f.Version != r.Requested) ||
+			f.Tableset != r.Tableset || strings.Join(f.Filters, ",") != strings.Join(r.Filters, ",") ||
+			strings.Join(f.Materialize, ",") != strings.Join(r.Materialize, ",") {
+			continue
+		}
+		if _, err := os.Stat(f.Path); err != nil {
+			continue
+		}
+		if f.Version != r.Requested {
+			c.cache.served(CachedResponse{Kind: MetaVersions, Key: key, Fetched: f.Time}, c.cache.TTL(MetaVersions))
+		}
+		return f, nil
+	}
+	return FetchRecord{}, fmt.Errorf("%s: %w: never fetched on this machine", strings.Join(key, " "), ErrNotCached)
+}
@@ -0,0 +1,438 @@
+package tidydata
+
+import (
+	"container/heap"
+	"database/sql"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"v.io/v23/context"
+)
+
+// ProfileOptions bounds the memory used to profile a column.
+type ProfileOptions struct {
+	// TopN is the number of most frequent values reported per column.
+	TopN int
+	// Bins is the number of histogram bins for numeric columns.
+	Bins int
+	// MaxDistinct is the number of distinct values tracked exactly per
+	// column; beyond it the distinct count is a lower bound.
+	MaxDistinct int
+}
+
+// DefaultProfileOptions are the options used when none are given.
+var DefaultProfileOptions = ProfileOptions{TopN: 10, Bins: 10, MaxDistinct: 100000}
+
+// ValueCount is a value and the number of rows containing it.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Bin is a histogram bin covering [Low, High), or [Low, High] for the last
+// bin.
+type Bin struct {
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+	Count int64   `json:"count"`
+}
+
+// ColumnProfile summarizes the values of a column.
+type ColumnProfile struct {
+	Name     string  `json:"name"`
+	Nulls    int64   `json:"nulls"`
+	NullRate float64 `json:"null_rate"`
+	Distinct int64   `json:"distinct"`
+	// DistinctExact is false if the column had more than
+	// ProfileOptions.MaxDistinct values, in which case Distinct is a lower
+	// bound.
+	DistinctExact bool   `json:"distinct_exact"`
+	Min           string `json:"min,omitempty"`
+	Max           string `json:"max,omitempty"`
+	// Numeric is set if every non-null value is a number, in which case
+	// Mean and Histogram are populated.
+	Numeric   bool    `json:"numeric"`
+	Mean      float64 `json:"mean,omitempty"`
+	Histogram []Bin   `json:"histogram,omitempty"`
+	// TopValues are the most frequent values. If DistinctExact is false
+	// the counts are lower bounds, and a value may be missing if it is not
+	// much more frequent than the MaxDistinct-th most frequent value.
+	TopValues []ValueCount `json:"top_values"`
+}
+
+// TableProfile summarizes the columns of a table.
+type TableProfile struct {
+	Table   string          `json:"table"`
+	Rows    int64           `json:"rows"`
+	Columns []ColumnProfile `json:"columns"`
+}
+
+// columnStats accumulates the profile of a column over a stream of values.
+type columnStats struct {
+	opts           ProfileOptions
+	nulls, nonNull int64
+	numeric        bool
+	sum            float64
+	low, high      float64
+	min, max       string
+	counts         map[string]*valueCounter
+	// heap orders counts by count once there are MaxDistinct of them.
+	heap     counterHeap
+	overflow bool
+	bins     []int64
+}
+
+func newColumnStats(opts ProfileOptions, low, high sql.NullFloat64) *columnStats {
+	s := &columnStats{opts: opts, numeric: true, counts: map[string]*valueCounter{}}
+	s.low, s.high = low.Float64, high.Float64
+	if low.Valid && high.Valid && opts.Bins > 0 {
+		s.bins = make([]int64, opts.Bins)
+	}
+	return s
+}
+
+// add accumulates a value as returned by the sqlite driver.
+func (s *columnStats) add(v interface{}) {
+	if v == nil {
+		s.nulls++
+		return
+	}
+	var str string
+	switch v := v.(type) {
+	case int64:
+		s.addNumber(float64(v))
+		str = fmt.Sprint(v)
+	case float64:
+		s.addNumber(v)
+		str = fmt.Sprint(v)
+	case []byte:
+		s.numeric = false
+		str = string(v)
+	case time.Time:
+		s.numeric = false
+		str = v.Format(time.RFC3339Nano)
+	default:
+		s.numeric = false
+		str = fmt.Sprint(v)
+	}
+	if s.nonNull == 0 || str < s.min {
+		s.min = str
+	}
+	if s.nonNull == 0 || str > s.max {
+		s.max = str
+	}
+	s.nonNull++
+	s.count(str)
+}
+
+func (s *columnStats) addNumber(f float64) {
+	s.sum += f
+	if len(s.bins) == 0 {
+		return
+	}
+	i := len(s.bins) - 1
+	if s.high > s.low {
+		i = int(float64(len(s.bins)) * (f - s.low) / (s.high - s.low))
+	}
+	if i >= len(s.bins) {
+		i = len(s.bins) - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	s.bins[i]++
+}
+
+// valueCounter is the count of a value in a columnStats summary. Once the
+// summary overflows, count may include up to err occurrences of the values
+// it replaced.
+type valueCounter struct {
+	value      string
+	count, err int64
+	index      int
+}
+
+// counterHeap is a min-heap of value counters ordered by count.
+type counterHeap []*valueCounter
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *counterHeap) Push(x interface{}) {
+	c := x.(*valueCounter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+func (h *counterHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// count tracks value frequencies exactly until MaxDistinct values have
+// been seen, and from then on as a space-saving summary: a value not yet
+// tracked replaces the least frequent one and inherits its count as error.
+// This keeps the most frequent values in bounded memory at O(log
+// MaxDistinct) per value.
+func (s *columnStats) count(str string) {
+	if c, ok := s.counts[str]; ok {
+		c.count++
+		if s.overflow {
+			heap.Fix(&s.heap, c.index)
+		}
+		return
+	}
+	if len(s.counts) < s.opts.MaxDistinct {
+		s.counts[str] = &valueCounter{value: str, count: 1}
+		return
+	}
+	if !s.overflow {
+		s.overflow = true
+		for _, c := range s.counts {
+			c.index = len(s.heap)
+			s.heap = append(s.heap, c)
+		}
+		heap.Init(&s.heap)
+	}
+	if len(s.heap) == 0 {
+		return
+	}
+	min := s.heap[0]
+	delete(s.counts, min.value)
+	min.value, min.err = str, min.count
+	min.count++
+	s.counts[str] = min
+	heap.Fix(&s.heap, 0)
+}
+
+func (s *columnStats) profile(name string, rows int64) ColumnProfile {
+	p := ColumnProfile{
+		Name:          name,
+		Nulls:         s.nulls,
+		Distinct:      int64(len(s.counts)),
+		DistinctExact: !s.overflow,
+		Min:           s.min,
+		Max:           s.max,
+		Numeric:       s.numeric && s.nonNull > 0,
+	}
+	if s.overflow {
+		p.Distinct = int64(s.opts.MaxDistinct)
+	}
+	if rows > 0 {
+		p.NullRate = float64(s.nulls) / float64(rows)
+	}
+	if p.Numeric {
+		// Compare numbers numerically rather than as strings.
+		p.Min, p.Max = fmt.Sprint(s.low), fmt.Sprint(s.high)
+		p.Mean = s.sum / float64(s.nonNull)
+	}
+	if p.Numeric && len(s.bins) > 0 {
+		width := (s.high - s.low) / float64(len(s.bins))
+		for i, n := range s.bins {
+			p.Histogram = append(p.Histogram, Bin{Low: s.low + float64(i)*width, High: s.low + float64(i+1)*width, Count: n})
+		}
+	}
+	for _, c := range s.counts {
+		p.TopValues = append(p.TopValues, ValueCount{c.value, c.count - c.err})
+	}
+	sort.Slice(p.TopValues, func(i, j int) bool {
+		if p.TopValues[i].Count != p.TopValues[j].Count {
+			return p.TopValues[i].Count > p.TopValues[j].Count
+		}
+		return p.TopValues[i].Value < p.TopValues[j].Value
+	})
+	if len(p.TopValues) > s.opts.TopN {
+		p.TopValues = p.TopValues[:s.opts.TopN]
+	}
+	return p
+}
+
+// ProfileTable profiles every column of a table in db. It makes one pass
+// to find the range of numeric values and a second, streaming, pass over
+// the rows, so memory use is bounded by opts rather than the table size.
+func ProfileTable(db *sql.DB, table string, opts ProfileOptions) (*TableProfile, error) {
+	columns, err := dbColumns(db, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist", table)
+	}
+	if opts == (ProfileOptions{}) {
+		opts = DefaultProfileOptions
+	}
+	var ranges []string
+	for _, col := range columns {
+		numeric := fmt.Sprintf("CASE WHEN typeof(%s) IN ('integer', 'real') THEN %s END", quoteIdent(col), quoteIdent(col))
+		ranges = append(ranges, fmt.Sprintf("MIN(%s), MAX(%s)", numeric, numeric))
+	}
+	bounds := make([]sql.NullFloat64, 2*len(columns))
+	dest := make([]interface{}, len(bounds))
+	for i := range bounds {
+		dest[i] = &bounds[i]
+	}
+	if err := db.QueryRow(fmt.Sprintf("SELECT %s FROM %s", strings.Join(ranges, ", "), quoteIdent(table))).Scan(dest...); err != nil {
+		return nil, err
+	}
+	stats := make([]*columnStats, len(columns))
+	for i := range columns {
+		stats[i] = newColumnStats(opts, bounds[2*i], bounds[2*i+1])
+	}
+
+	var quoted []string
+	for _, col := range columns {
+		quoted = append(quoted, quoteIdent(col))
+	}
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), quoteIdent(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := make([]interface{}, len(columns))
+	dest = make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	profile := &TableProfile{Table: table}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		profile.Rows++
+		for i, v := range values {
+			stats[i].add(v)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, col := range columns {
+		profile.Columns = append(profile.Columns, stats[i].profile(col, profile.Rows))
+	}
+	return profile, nil
+}
+
+// ProfileRequest identifies the tables to profile.
+type ProfileRequest struct {
+	Dataset  string
+	Version  string
+	Tableset string
+	// Table, if set, is the only table profiled.
+	Table string
+	// Path is the local tidyset database. If empty, the tidyset is fetched
+	// into the local cache.
+	Path    string
+	Options ProfileOptions
+}
+
+// Profile profiles the tables of a local tidyset database.
+func (c *Client) Profile(ctx *context.T, req ProfileRequest) ([]*TableProfile, error) {
+	if req.Path == "" {
+		fetched, err := c.Tidyset(ctx, TidysetRequest{Dataset: req.Dataset, Version: req.Version, Tableset: req.Tableset})
+		if err != nil {
+			return nil, err
+		}
+		req.Path = fetched.Path
+	}
+	tables := []string{req.Table}
+	if req.Table == "" {
+		var err error
+		if tables, err = c.ListTables(ctx, req.Dataset, req.Version, req.Tableset); err != nil {
+			return nil, err
+		}
+	}
+	db, err := OpenDB(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var profiles []*TableProfile
+	for _, table := range tables {
+		p, err := ProfileTable(db, table, req.Options)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't profile %s: %v", table, err)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// WriteProfiles writes table profiles as text or HTML.
+func WriteProfiles(w io.Writer, profiles []*TableProfile, format string) error {
+	switch format {
+	case "text":
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, p := range profiles {
+			fmt.Fprintf(tw, "%s (%d rows)\n", p.Table, p.Rows)
+			fmt.Fprintf(tw, "  column\tnull rate\tdistinct\tmin\tmax\ttop values\n")
+			for _, col := range p.Columns {
+				fmt.Fprintf(tw, "  %s\t%.2f%%\t%s\t%s\t%s\t%s\n", col.Name, 100*col.NullRate, distinctString(col), col.Min, col.Max, topValuesString(col.TopValues))
+			}
+		}
+		return tw.Flush()
+	case "html":
+		tmpl := htmltemplate.Must(htmltemplate.New("profile").Funcs(profileFuncs).Parse(profileHTML))
+		return tmpl.Execute(w, profiles)
+	}
+	return fmt.Errorf("unsupported profile format %q: must be text, json or html", format)
+}
+
+func distinctString(col ColumnProfile) string {
+	if col.DistinctExact {
+		return fmt.Sprint(col.Distinct)
+	}
+	return fmt.Sprintf(">%d", col.Distinct)
+}
+
+func topValuesString(values []ValueCount) string {
+	var parts []string
+	for _, v := range values {
+		parts = append(parts, fmt.Sprintf("%s (%d)", v.Value, v.Count))
+	}
+	return strings.Join(parts, ", ")
+}
+
+var profileFuncs = htmltemplate.FuncMap{
+	"distinct": distinctString,
+	"percent":  func(f float64) string { return fmt.Sprintf("%.2f%%", 100*f) },
+	// width scales a histogram bin to a bar width in pixels.
+	"width": func(n int64, bins []Bin) int64 {
+		var max int64
+		for _, b := range bins {
+			if b.Count > max {
+				max = b.Count
+			}
+		}
+		if max == 0 {
+			return 0
+		}
+		return 200 * n / max
+	},
+}
+
+const profileHTML = `<html>
+<head><title>Column profile</title></head>
+<body>
+{{range .}}<h2>{{.Table}}</h2>
+<p>{{.Rows}} rows</p>
+<table border="1">
+<tr><th>Column</th><th>Null rate</th><th>Distinct</th><th>Min</th><th>Max</th><th>Mean</th><th>Top values</th><th>Histogram</th></tr>
+{{range .Columns}}<tr>
+<td>{{.Name}}</td><td>{{percent .NullRate}}</td><td>{{distinct .}}</td><td>{{.Min}}</td><td>{{.Max}}</td>
+<td>{{if .Numeric}}{{.Mean}}{{end}}</td>
+<td>{{range .TopValues}}{{.Value}} ({{.Count}})<br>{{end}}</td>
+<td>{{$bins := .Histogram}}{{range $bins}}<div title="{{.Low}} to {{.High}}: {{.Count}}" style="background:#4a7;height:8px;width:{{width .Count $bins}}px"></div>{{end}}</td>
+</tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`
@@ -0,0 +1,109 @@
+package tidydata
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestColumnStatsCount(t *testing.T) {
+	for _, test := range []struct {
+		maxDistinct int
+		values      string
+		distinct    int64
+		exact       bool
+		top         []ValueCount
+	}{
+		{10, "", 0, true, nil},
+		{10, "aabac", 3, true, []ValueCount{{"a", 3}, {"b", 1}, {"c", 1}}},
+		{3, "aaabbc", 3, true, []ValueCount{{"a", 3}, {"b", 2}, {"c", 1}}},
+		// Once the summary is full, a new value replaces the least frequent,
+		// whose count is carried as error so reported counts are lower
+		// bounds, and the frequent values survive.
+		{2, "aaaabbcd", 2, false, []ValueCount{{"a", 4}, {"d", 1}}},
+		{2, "aaaabcbb", 2, false, []ValueCount{{"a", 4}, {"b", 2}}},
+		{0, "abc", 0, false, nil},
+	} {
+		s := newColumnStats(ProfileOptions{TopN: 10, MaxDistinct: test.maxDistinct}, sql.NullFloat64{}, sql.NullFloat64{})
+		for _, v := range test.values {
+			s.count(string(v))
+		}
+		p := s.profile("c", int64(len(test.values)))
+		if p.Distinct != test.distinct || p.DistinctExact != test.exact || !reflect.DeepEqual(p.TopValues, test.top) {
+			t.Errorf("count(%q) with MaxDistinct %d: distinct %d, exact %v, top %v; want %d, %v, %v",
+				test.values, test.maxDistinct, p.Distinct, p.DistinctExact, p.TopValues, test.distinct, test.exact, test.top)
+		}
+	}
+}
+
+// TestColumnStatsOverflow fills the summary with values whose counts
+// already form a heap, so that building it moves none of them, overflows
+// it, and then makes one of the values frequent. The summary must stay a
+// consistent heap and keep the frequent value: a value occurring in more
+// than 1/MaxDistinct of the rows is always kept.
+func TestColumnStatsOverflow(t *testing.T) {
+	const maxDistinct = 7
+	s := newColumnStats(ProfileOptions{TopN: 2, MaxDistinct: maxDistinct}, sql.NullFloat64{}, sql.NullFloat64{})
+	for i := 0; i < maxDistinct; i++ {
+		for j := 0; j <= i; j++ {
+			s.count(fmt.Sprint("seed", i))
+		}
+	}
+	s.count("rare")
+	for i := 0; i < 50; i++ {
+		s.count("seed1")
+	}
+	for i := 0; i < 50; i++ {
+		s.count(fmt.Sprint("rare", i))
+	}
+	for i, c := range s.heap {
+		if c.index != i {
+			t.Errorf("counter %s is at %d but records index %d", c.value, i, c.index)
+		}
+		if s.counts[c.value] != c {
+			t.Errorf("counter %s at %d is not tracked", c.value, i)
+		}
+		if j := (i - 1) / 2; i > 0 && s.heap[j].count > c.count {
+			t.Errorf("heap order violated: %s (%d) above %s (%d)", s.heap[j].value, s.heap[j].count, c.value, c.count)
+		}
+	}
+	p := s.profile("c", 0)
+	if len(p.TopValues) == 0 || p.TopValues[0].Value != "seed1" {
+		t.Fatalf("top values %v, want seed1 first", p.TopValues)
+	}
+	if got := p.TopValues[0].Count; got < 50 || got > 52 {
+		t.Errorf("count of seed1 is %d, want between its 50 occurrences since overflow and its 52 in all", got)
+	}
+}
+
+func TestColumnStatsProfile(t *testing.T) {
+	s := newColumnStats(ProfileOptions{TopN: 2, Bins: 2, MaxDistinct: 10}, sql.NullFloat64{Float64: 1, Valid: true}, sql.NullFloat64{Float64: 4, Valid: true})
+	for _, v := range []interface{}{int64(1), float64(2), nil, int64(4), int64(4)} {
+		s.add(v)
+	}
+	want := ColumnProfile{
+		Name:          "n",
+		Nulls:         1,
+		NullRate:      0.2,
+		Distinct:      3,
+		DistinctExact: true,
+		Min:           "1",
+		Max:           "4",
+		Numeric:       true,
+		Mean:          2.75,
+		Histogram:     []Bin{{Low: 1, High: 2.5, Count: 2}, {Low: 2.5, High: 4, Count: 2}},
+		TopValues:     []ValueCount{{"4", 2}, {"1", 1}},
+	}
+	if got := s.profile("n", 5); !reflect.DeepEqual(got, want) {
+		t.Errorf("profile = %+v, want %+v", got, want)
+	}
+
+	s = newColumnStats(ProfileOptions{TopN: 10, MaxDistinct: 10}, sql.NullFloat64{}, sql.NullFloat64{})
+	for _, v := range []interface{}{[]byte("b"), "a", int64(3)} {
+		s.add(v)
+	}
+	if got := s.profile("s", 3); got.Numeric || got.Min != "3" || got.Max != "b" {
+		t.Errorf("profile of mixed column = %+v, want non-numeric with min 3 and max b", got)
+	}
+}
@@ -0,0 +1,233 @@
+package tidydata
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Phases of a fetch reported in progress events.
+const (
+	// PhaseFetching is the request to the server, which materializes the
+	// data and downloads it into the local cache. The server reports no
+	// progress, so the bytes fetched so far are unknown.
+	PhaseFetching = "fetching"
+	// PhaseCopying is the copy of the data to the requested output path.
+	PhaseCopying = "copying"
+	// PhaseDone ends every fetch, successful or not.
+	PhaseDone = "done"
+)
+
+// DefaultProgressInterval is the default time between progress events
+// within a phase.
+const DefaultProgressInterval = 500 * time.Millisecond
+
+// ProgressEvent reports the progress of a fetch.
+type ProgressEvent struct {
+	Time time.Time `json:"time"`
+	// Kind is KindTidyset or KindPreprocessed.
+	Kind      string `json:"kind"`
+	Dataset   string `json:"dataset"`
+	Requested string `json:"requested"`
+	Tableset  string `json:"tableset,omitempty"`
+	Phase     string `json:"phase"`
+	// Bytes is the number of bytes copied so far in the copying phase, or
+	// fetched in the done event, and Total the expected number, or 0 if
+	// unknown. While fetching, Bytes is 0 and Total the size of the most
+	// recent earlier fetch of the same data.
+	Bytes int64 `json:"bytes"`
+	Total int64 `json:"total,omitempty"`
+	// Rate is the mean rate of the phase in bytes per second.
+	Rate float64 `json:"rate"`
+	// ElapsedMS is the time since the fetch started, and ETAMS the
+	// estimated time until the end of the phase, or 0 if unknown, both in
+	// milliseconds. While fetching, the estimate is based on the duration
+	// of the most recent earlier fetch of the same data.
+	ElapsedMS int64 `json:"elapsed_ms"`
+	ETAMS     int64 `json:"eta_ms,omitempty"`
+	// Path and Version are set in the done event of a successful fetch,
+	// and Error in that of a failed one.
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Progress configures the progress reporting of fetches.
+//
+// The tidy server reports no progress, so a request to it is reported as a
+// single fetching phase whose size and duration are estimated from the most
+// recent earlier fetch of the same data, in any version, in the client's
+// fetch log. Only the copy to an output path is measured.
+type Progress struct {
+	// Report is called with each event, from one goroutine at a time.
+	Report func(ProgressEvent)
+	// Interval is the time between events within a phase. It defaults to
+	// DefaultProgressInterval.
+	Interval time.Duration
+}
+
+// SetProgress makes the client report the progress of the tidysets and
+// preprocessed data it fetches to p. A nil p disables reporting.
+func (c *Client) SetProgress(p *Progress) {
+	c.progress = p
+}
+
+// fetchProgress reports the progress of one fetch. Its methods do nothing
+// on a nil fetchProgress.
+type fetchProgress struct {
+	*Progress
+	base  ProgressEvent
+	start time.Time
+	// total and duration are the expected size and duration of the
+	// fetch, if known.
+	total    int64
+	duration time.Duration
+}
+
+// startProgress returns the progress reporter of the fetch described by r,
+// or nil if the client reports no progress.
+func (c *Client) startProgress(r FetchRecord, start time.Time) *fetchProgress {
+	if c.progress == nil {
+		return nil
+	}
+	p := &fetchProgress{
+		Progress: c.progress,
+		base:     ProgressEvent{Kind: r.Kind, Dataset: r.Dataset, Requested: r.Requested, Tableset: r.Tableset},
+		start:    start,
+	}
+	if p.Interval <= 0 {
+		p.Interval = DefaultProgressInterval
+	}
+	if c.fetchLog == nil {
+		return p
+	}
+	records, err := c.fetchLog.Records()
+	if err != nil {
+		return p
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		f := records[i]
+		if f.Error != "" || f.Offline || f.CacheHit || f.Path == "" {
+			continue
+		}
+		if f.Kind == r.Kind && f.Dataset == r.Dataset && f.Tableset == r.Tableset &&
+			strings.Join(f.Filters, ",") == strings.Join(r.Filters, ",") &&
+			strings.Join(f.Materialize, ",") == strings.Join(r.Materialize, ",") {
+			p.total = f.Size
+			p.duration = time.Duration(f.DurationMS) * time.Millisecond
+			break
+		}
+	}
+	return p
+}
+
+// event returns an event of the given phase at the current time.
+func (p *fetchProgress) event(phase string) ProgressEvent {
+	e := p.base
+	e.Time = time.Now().UTC()
+	e.Phase = phase
+	e.ElapsedMS = int64(e.Time.Sub(p.start) / time.Millisecond)
+	return e
+}
+
+// report emits an event for a phase that started at since.
+func (p *fetchProgress) report(phase string, since time.Time, bytes, total int64) {
+	e := p.event(phase)
+	e.Bytes, e.Total = bytes, total
+	if d := e.Time.Sub(since).Seconds(); d > 0 {
+		e.Rate = float64(bytes) / d
+	}
+	if e.Rate > 0 && total > bytes {
+		e.ETAMS = int64(float64(total-bytes) / e.Rate * 1000)
+	}
+	p.Report(e)
+}
+
+// download calls fetch, which returns the path and version of the fetched
+// data, reporting that it is fetching while it runs.
+func (p *fetchProgress) download(fetch func() (string, string, error)) (string, string, error) {
+	if p == nil {
+		return fetch()
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+		for {
+			e := p.event(PhaseFetching)
+			e.Total = p.total
+			if remaining := p.duration - time.Duration(e.ElapsedMS)*time.Millisecond; remaining > 0 {
+				e.ETAMS = int64(remaining / time.Millisecond)
+			}
+			p.Report(e)
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	path, version, err := fetch()
+	close(stop)
+	wg.Wait()
+	return path, version, err
+}
+
+// copy copies src to dst, reporting the progress of the copy.
+func (p *fetchProgress) copy(src, dst string) error {
+	if p == nil {
+		return CopyFile(src, dst)
+	}
+	var total int64
+	if info, err := os.Stat(src); err == nil {
+		total = info.Size()
+	}
+	start := time.Now()
+	last := start
+	p.report(PhaseCopying, start, 0, total)
+	return copyFile(src, dst, func(n int64) {
+		if now := time.Now(); now.Sub(last) >= p.Interval || n == total {
+			last = now
+			p.report(PhaseCopying, start, n, total)
+		}
+	})
+}
+
+// done emits the final event of the fetch.
+func (p *fetchProgress) done(path, version string, err error) {
+	if p == nil {
+		return
+	}
+	e := p.event(PhaseDone)
+	if err != nil {
+		e.Error = err.Error()
+	} else {
+		e.Path, e.Version = path, version
+		if info, serr := os.Stat(path); serr == nil {
+			e.Bytes, e.Total = info.Size(), info.Size()
+			if d := e.Time.Sub(p.start).Seconds(); d > 0 {
+				e.Rate = float64(e.Bytes) / d
+			}
+		}
+	}
+	p.Report(e)
+}
+
+// progressWriter counts the bytes written through it.
+type progressWriter struct {
+	w        io.Writer
+	n        int64
+	progress func(n int64)
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	w.progress(w.n)
+	return n, err
+}
@@ -0,0 +1,150 @@
+package tidydata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"v.io/v23/context"
+)
+
+// ProtectedAlias is an alias that may only be changed with an explicit
+// override and reason.
+type ProtectedAlias struct {
+	// Dataset is the dataset of the alias, or "*" for every dataset.
+	Dataset string `json:"dataset"`
+	Alias   string `json:"alias"`
+	// Consumers lists the teams or systems known to depend on the alias.
+	Consumers []string `json:"consumers,omitempty"`
+}
+
+// AliasPolicy lists the protected aliases.
+type AliasPolicy struct {
+	Protected []ProtectedAlias `json:"protected_aliases"`
+}
+
+// Lookup returns the protection of an alias, or nil if it is not
+// protected.
+func (p *AliasPolicy) Lookup(dataset, alias string) *ProtectedAlias {
+	if p == nil {
+		return nil
+	}
+	for i, a := range p.Protected {
+		if a.Alias == alias && (a.Dataset == dataset || a.Dataset == "*") {
+			return &p.Protected[i]
+		}
+	}
+	return nil
+}
+
+// SetAliasPolicy makes the client refuse to change the aliases protected
+// by p unless overridden with SetOverride. A nil p protects no aliases.
+func (c *Client) SetAliasPolicy(p *AliasPolicy) {
+	c.policy = p
+}
+
+// SetOverride allows the client to change protected aliases, recording
+// reason in the audit log. An empty reason withdraws the override.
+func (c *Client) SetOverride(reason string) {
+	c.override = reason
+}
+
+// AliasImpact describes how an operation affects a protected alias.
+type AliasImpact struct {
+	Dataset string `json:"dataset"`
+	Alias   string `json:"alias"`
+	// From and To are the versions the alias points to before and after
+	// the operation; either is empty if the alias does not exist.
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Consumers []string `json:"consumers,omitempty"`
+	// LocalFetches counts the fetches through the alias in the client's
+	// fetch log.
+	LocalFetches int `json:"local_fetches"`
+}
+
+// ProtectedAliasError is returned by the administrative operations that
+// would change protected aliases without an override.
+type ProtectedAliasError struct {
+	Impacts []AliasImpact
+}
+
+func (e *ProtectedAliasError) Error() string {
+	var aliases []string
+	for _, i := range e.Impacts {
+		aliases = append(aliases, i.Alias)
+	}
+	return fmt.Sprintf("alias %s of %s is protected and needs an override with a reason to change", strings.Join(aliases, ", "), e.Impacts[0].Dataset)
+}
+
+// aliasTargets returns the aliases an operation changes, mapped to the
+// versions they will point to given the current aliases.
+func aliasTargets(op string, args []string, current map[string]string) map[string]string {
+	switch op {
+	case OpAddAlias:
+		return map[string]string{args[2]: args[1]}
+	case OpUpdateAlias:
+		return map[string]string{args[1]: "", args[2]: current[args[1]]}
+	case OpRemoveAlias:
+		return map[string]string{args[1]: ""}
+	}
+	return nil
+}
+
+// AliasImpact returns how the administrative operation op, with the
+// arguments of its version subcommand, would affect protected aliases.
+func (c *Client) AliasImpact(ctx *context.T, op string, args []string) ([]AliasImpact, error) {
+	if c.policy == nil || len(args) == 0 {
+		return nil, nil
+	}
+	dataset := args[0]
+	targets := aliasTargets(op, args, nil)
+	protected := false
+	for alias := range targets {
+		protected = protected || c.policy.Lookup(dataset, alias) != nil
+	}
+	if !protected {
+		return nil, nil
+	}
+	snap, err := c.Snapshot(ctx, dataset)
+	if err != nil {
+		return nil, err
+	}
+	var records []FetchRecord
+	if c.fetchLog != nil {
+		if records, err = c.fetchLog.Records(); err != nil {
+			return nil, err
+		}
+	}
+	targets = aliasTargets(op, args, snap.Aliases)
+	var impacts []AliasImpact
+	for _, alias := range sortedKeys(targets) {
+		p := c.policy.Lookup(dataset, alias)
+		if p == nil {
+			continue
+		}
+		impact := AliasImpact{
+			Dataset:   dataset,
+			Alias:     alias,
+			From:      snap.Aliases[alias],
+			To:        targets[alias],
+			Consumers: p.Consumers,
+		}
+		for _, r := range records {
+			if r.Error == "" && r.Dataset == dataset && r.Requested == alias {
+				impact.LocalFetches++
+			}
+		}
+		impacts = append(impacts, impact)
+	}
+	return impacts, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
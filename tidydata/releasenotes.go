@@ -0,0 +1,242 @@
+package tidydata
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+
+	"v.io/v23/context"
+)
+
+// DefaultReleaseNotesURL is the template for the published release notes.
+const DefaultReleaseNotesURL = "https://confluence.ti-apps.aws.grail.com/display/TIDY/Release+{version}-{dataset}-Tidydata+Software+Release+Notes"
+
+// ReleaseNotesURL expands a release notes URL template, in which
+// {dataset} and {version} are replaced, for a dataset and version.
+func ReleaseNotesURL(template, dataset, version string) string {
+	return strings.NewReplacer("{dataset}", dataset, "{version}", version).Replace(template)
+}
+
+// TableChange describes how a table present in both versions changed.
+type TableChange struct {
+	Name           string
+	OldRows        int64
+	NewRows        int64
+	AddedColumns   []string
+	RemovedColumns []string
+}
+
+// TablesetChange describes how a tableset present in both versions changed.
+type TablesetChange struct {
+	Name          string
+	AddedTables   []string
+	RemovedTables []string
+	ChangedTables []TableChange
+}
+
+// FilterChange describes a filter whose query string changed.
+type FilterChange struct {
+	Name     string
+	OldQuery string
+	NewQuery string
+}
+
+// ReleaseNotes is the structural comparison of two versions of a dataset
+// from which release notes are rendered.
+type ReleaseNotes struct {
+	Dataset            string
+	OldVersion         string
+	NewVersion         string
+	VersionDescription string
+	Description        string
+	URL                string
+	AddedTablesets     []string
+	RemovedTablesets   []string
+	ChangedTablesets   []TablesetChange
+	AddedFilters       []string
+	RemovedFilters     []string
+	ChangedFilters     []FilterChange
+}
+
+// diffNames returns the names only in newNames and the names only in
+// oldNames, each in the order they appear.
+func diffNames(oldNames, newNames []string) (added, removed []string) {
+	oldSet, newSet := map[string]bool{}, map[string]bool{}
+	for _, n := range oldNames {
+		oldSet[n] = true
+	}
+	for _, n := range newNames {
+		newSet[n] = true
+		if !oldSet[n] {
+			added = append(added, n)
+		}
+	}
+	for _, n := range oldNames {
+		if !newSet[n] {
+			removed = append(removed, n)
+		}
+	}
+	return added, removed
+}
+
+// CompareVersions builds release notes from the structures of two versions.
+// The URL of the notes is expanded from urlTemplate.
+func CompareVersions(oldVS, newVS *VersionStructure, urlTemplate string) *ReleaseNotes {
+	notes := &ReleaseNotes{
+		Dataset:     newVS.Dataset,
+		OldVersion:  oldVS.Version,
+		NewVersion:  newVS.Version,
+		Description: newVS.Description,
+		URL:         ReleaseNotesURL(urlTemplate, newVS.Dataset, newVS.Version),
+	}
+	notes.AddedTablesets, notes.RemovedTablesets = diffNames(oldVS.TablesetNames(), newVS.TablesetNames())
+	for _, name := range newVS.TablesetNames() {
+		oldTS, ok := oldVS.Tablesets[name]
+		if !ok {
+			continue
+		}
+		newTS := newVS.Tablesets[name]
+		change := TablesetChange{Name: name}
+		change.AddedTables, change.RemovedTables = diffNames(oldTS.TableNames(), newTS.TableNames())
+		for _, tname := range newTS.TableNames() {
+			oldT, ok := oldTS.Tables[tname]
+			if !ok {
+				continue
+			}
+			newT := newTS.Tables[tname]
+			tc := TableChange{Name: tname, OldRows: oldT.NumRows, NewRows: newT.NumRows}
+			tc.AddedColumns, tc.RemovedColumns = diffNames(oldT.Columns, newT.Columns)
+			if tc.OldRows != tc.NewRows || len(tc.AddedColumns) > 0 || len(tc.RemovedColumns) > 0 {
+				change.ChangedTables = append(change.ChangedTables, tc)
+			}
+		}
+		if len(change.AddedTables) > 0 || len(change.RemovedTables) > 0 || len(change.ChangedTables) > 0 {
+			notes.ChangedTablesets = append(notes.ChangedTablesets, change)
+		}
+	}
+	notes.AddedFilters, notes.RemovedFilters = diffNames(oldVS.FilterNames(), newVS.FilterNames())
+	for _, name := range newVS.FilterNames() {
+		oldF, ok := oldVS.Filters[name]
+		if !ok {
+			continue
+		}
+		if newF := newVS.Filters[name]; oldF.QueryString != newF.QueryString {
+			notes.ChangedFilters = append(notes.ChangedFilters, FilterChange{name, oldF.QueryString, newF.QueryString})
+		}
+	}
+	return notes
+}
+
+var releaseNotesFuncs = map[string]interface{}{
+	"join":  func(s []string) string { return strings.Join(s, ", ") },
+	"delta": func(old, new int64) string { return fmt.Sprintf("%+d", new-old) },
+}
+
+const releaseNotesMarkdown = `# {{.Dataset}} {{.NewVersion}} release notes
+
+Changes since {{.OldVersion}}. Published notes: {{.URL}}
+{{if .VersionDescription}}
+{{.VersionDescription}}
+{{end}}
+## Description
+
+{{.Description}}
+
+## Tablesets
+{{if not (or .AddedTablesets .RemovedTablesets .ChangedTablesets)}}
+No tableset changes.
+{{end}}{{range .AddedTablesets}}
+- Added tableset ` + "`{{.}}`" + `{{end}}{{range .RemovedTablesets}}
+- Removed tableset ` + "`{{.}}`" + `{{end}}
+{{range .ChangedTablesets}}
+### {{.Name}}
+{{range .AddedTables}}
+- Added table ` + "`{{.}}`" + `{{end}}{{range .RemovedTables}}
+- Removed table ` + "`{{.}}`" + `{{end}}{{range .ChangedTables}}
+- Table ` + "`{{.Name}}`" + `: rows {{.OldRows}} -> {{.NewRows}} ({{delta .OldRows .NewRows}}){{if .AddedColumns}}; added columns {{join .AddedColumns}}{{end}}{{if .RemovedColumns}}; removed columns {{join .RemovedColumns}}{{end}}{{end}}
+{{end}}
+## Filters
+{{if not (or .AddedFilters .RemovedFilters .ChangedFilters)}}
+No filter changes.
+{{end}}{{range .AddedFilters}}
+- Added filter ` + "`{{.}}`" + `{{end}}{{range .RemovedFilters}}
+- Removed filter ` + "`{{.}}`" + `{{end}}{{range .ChangedFilters}}
+- Filter ` + "`{{.Name}}`" + ` query changed from ` + "`{{.OldQuery}}`" + ` to ` + "`{{.NewQuery}}`" + `{{end}}
+`
+
+const releaseNotesHTML = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Dataset}} {{.NewVersion}} release notes</title></head>
+<body>
+<h1>{{.Dataset}} {{.NewVersion}} release notes</h1>
+<p>Changes since {{.OldVersion}}. Published notes: <a href="{{.URL}}">{{.URL}}</a></p>
+{{if .VersionDescription}}<p>{{.VersionDescription}}</p>{{end}}
+<h2>Description</h2>
+<pre>{{.Description}}</pre>
+<h2>Tablesets</h2>
+{{if not (or .AddedTablesets .RemovedTablesets .ChangedTablesets)}}<p>No tableset changes.</p>{{end}}
+<ul>
+{{range .AddedTablesets}}<li>Added tableset <code>{{.}}</code></li>
+{{end}}{{range .RemovedTablesets}}<li>Removed tableset <code>{{.}}</code></li>
+{{end}}</ul>
+{{range .ChangedTablesets}}<h3>{{.Name}}</h3>
+<ul>
+{{range .AddedTables}}<li>Added table <code>{{.}}</code></li>
+{{end}}{{range .RemovedTables}}<li>Removed table <code>{{.}}</code></li>
+{{end}}{{range .ChangedTables}}<li>Table <code>{{.Name}}</code>: rows {{.OldRows}} &rarr; {{.NewRows}} ({{delta .OldRows .NewRows}}){{if .AddedColumns}}; added columns {{join .AddedColumns}}{{end}}{{if .RemovedColumns}}; removed columns {{join .RemovedColumns}}{{end}}</li>
+{{end}}</ul>
+{{end}}
+<h2>Filters</h2>
+{{if not (or .AddedFilters .RemovedFilters .ChangedFilters)}}<p>No filter changes.</p>{{end}}
+<ul>
+{{range .AddedFilters}}<li>Added filter <code>{{.}}</code></li>
+{{end}}{{range .RemovedFilters}}<li>Removed filter <code>{{.}}</code></li>
+{{end}}{{range .ChangedFilters}}<li>Filter <code>{{.Name}}</code> query changed from <code>{{.OldQuery}}</code> to <code>{{.NewQuery}}</code></li>
+{{end}}</ul>
+</body>
+</html>
+`
+
+// WriteReleaseNotes renders notes to w in the given format, either
+// "markdown" or "html".
+func WriteReleaseNotes(w io.Writer, notes *ReleaseNotes, format string) error {
+	switch format {
+	case "markdown", "md":
+		tmpl := texttemplate.Must(texttemplate.New("notes").Funcs(releaseNotesFuncs).Parse(releaseNotesMarkdown))
+		return tmpl.Execute(w, notes)
+	case "html":
+		tmpl := htmltemplate.Must(htmltemplate.New("notes").Funcs(releaseNotesFuncs).Parse(releaseNotesHTML))
+		return tmpl.Execute(w, notes)
+	}
+	return fmt.Errorf("unsupported release notes format %q: must be markdown or html", format)
+}
+
+// ReleaseNotesRequest identifies the versions to compare in release notes.
+type ReleaseNotesRequest struct {
+	Dataset    string
+	OldVersion string
+	NewVersion string
+	// URLTemplate is the template for the URL of the published notes; see
+	// ReleaseNotesURL.
+	URLTemplate string
+}
+
+// GenerateReleaseNotes builds release notes from the version description of
+// req.NewVersion and a structural comparison of the two versions.
+func (c *Client) GenerateReleaseNotes(ctx *context.T, req ReleaseNotesRequest) (*ReleaseNotes, error) {
+	oldVS, err := c.DescribeStructure(ctx, req.Dataset, req.OldVersion)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't describe %s %s: %v", req.Dataset, req.OldVersion, err)
+	}
+	newVS, err := c.DescribeStructure(ctx, req.Dataset, req.NewVersion)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't describe %s %s: %v", req.Dataset, req.NewVersion, err)
+	}
+	notes := CompareVersions(oldVS, newVS, req.URLTemplate)
+	if notes.VersionDescription, err = c.VersionDescription(ctx, req.Dataset, req.NewVersion); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
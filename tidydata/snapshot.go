@@ -0,0 +1,154 @@
+package tidydata
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+)
+
+// Snapshot describes a clinical data snapshot.
+type Snapshot struct {
+	// Raw is the snapshot string reported by the server.
+	Raw string `json:"snapshot"`
+	// Date is the snapshot date in YYYY-MM-DD form, if Raw contains one.
+	Date string `json:"date,omitempty"`
+	// Source is the source system, taken to be the rest of Raw once the
+	// date is removed.
+	Source string `json:"source,omitempty"`
+	// Versions lists the versions that include the snapshot.
+	Versions []string `json:"versions,omitempty"`
+}
+
+var snapshotDate = regexp.MustCompile(`(\d{4})[-_]?(\d{2})[-_]?(\d{2})`)
+
+// ParseSnapshot extracts the date and source system from a snapshot string
+// such as "epic_2024-03-01" or "20240301-rave".
+func ParseSnapshot(raw string) Snapshot {
+	s := Snapshot{Raw: raw}
+	loc := snapshotDate.FindStringSubmatchIndex(raw)
+	if loc == nil {
+		s.Source = raw
+		return s
+	}
+	s.Date = fmt.Sprintf("%s-%s-%s", raw[loc[2]:loc[3]], raw[loc[4]:loc[5]], raw[loc[6]:loc[7]])
+	s.Source = strings.Trim(raw[:loc[0]]+"-"+raw[loc[1]:], "-_./: ")
+	return s
+}
+
+// Matches reports whether name identifies the snapshot, either exactly or
+// by its date.
+func (s Snapshot) Matches(name string) bool {
+	return name == s.Raw || (s.Date != "" && name == s.Date)
+}
+
+// SnapshotsRequest selects the snapshots to list.
+type SnapshotsRequest struct {
+	Dataset string
+	// Version, if set, restricts the listing to the snapshots included in
+	// that version.
+	Version string
+	// MinState is the minimum publish state of the versions searched for
+	// each snapshot.
+	MinState vdl.State
+}
+
+// ListSnapshotDetails lists the snapshots of a dataset, or of one version
+// of it, along with their dates, source systems and every version, at or
+// above req.MinState, that includes them. Snapshots are sorted by date.
+func (c *Client) ListSnapshotDetails(ctx *context.T, req SnapshotsRequest) ([]Snapshot, error) {
+	versions, err := c.ListVersions(ctx, VersionsRequest{Dataset: req.Dataset, MinState: req.MinState})
+	if err != nil {
+		return nil, err
+	}
+	byRaw := map[string]*Snapshot{}
+	for _, v := range versions {
+		if IsFailedState(v.State) {
+			continue
+		}
+		snapshots, err := c.ListSnapshots(ctx, req.Dataset, v.Version)
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range snapshots {
+			s, ok := byRaw[raw]
+			if !ok {
+				parsed := ParseSnapshot(raw)
+				s = &parsed
+				byRaw[raw] = s
+			}
+			s.Versions = append(s.Versions, v.Version)
+		}
+	}
+	var include map[string]bool
+	if req.Version != "" {
+		snapshots, err := c.ListSnapshots(ctx, req.Dataset, req.Version)
+		if err != nil {
+			return nil, err
+		}
+		include = map[string]bool{}
+		for _, raw := range snapshots {
+			include[raw] = true
+			if _, ok := byRaw[raw]; !ok {
+				parsed := ParseSnapshot(raw)
+				parsed.Versions = []string{req.Version}
+				byRaw[raw] = &parsed
+			}
+		}
+	}
+	var result []Snapshot
+	for raw, s := range byRaw {
+		if include == nil || include[raw] {
+			result = append(result, *s)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date != result[j].Date {
+			return result[i].Date < result[j].Date
+		}
+		return result[i].Raw < result[j].Raw
+	})
+	return result, nil
+}
+
+// VersionForSnapshot returns the newest version of a dataset, at or above
+// minState, that includes the named snapshot; see Snapshot.Matches.
+func (c *Client) VersionForSnapshot(ctx *context.T, dataset, snapshot string, minState vdl.State) (string, error) {
+	snapshots, err := c.ListSnapshotDetails(ctx, SnapshotsRequest{Dataset: dataset, MinState: minState})
+	if err != nil {
+		return "", err
+	}
+	newest := ""
+	for _, s := range snapshots {
+		if !s.Matches(snapshot) {
+			continue
+		}
+		for _, v := range s.Versions {
+			if newest == "" || NaturalLess(newest, v) {
+				newest = v
+			}
+		}
+	}
+	if newest == "" {
+		return "", fmt.Errorf("no version of %s at state %s or above includes snapshot %s", dataset, minState, snapshot)
+	}
+	return newest, nil
+}
+
+// CheckVersionSnapshot returns an error if version does not include the
+// named snapshot.
+func (c *Client) CheckVersionSnapshot(ctx *context.T, dataset, version, snapshot string) error {
+	snapshots, err := c.ListSnapshots(ctx, dataset, version)
+	if err != nil {
+		return err
+	}
+	for _, raw := range snapshots {
+		if ParseSnapshot(raw).Matches(snapshot) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s does not include snapshot %s", dataset, version, snapshot)
+}
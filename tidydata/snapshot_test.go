@@ -0,0 +1,36 @@
+package tidydata
+
+import "testing"
+
+func TestParseSnapshot(t *testing.T) {
+	for raw, want := range map[string]Snapshot{
+		"epic_2024-03-01": {Date: "2024-03-01", Source: "epic"},
+		"20240301-rave":   {Date: "2024-03-01", Source: "rave"},
+		"rave.20240301":   {Date: "2024-03-01", Source: "rave"},
+		"2024_03_01":      {Date: "2024-03-01"},
+		"epic":            {Source: "epic"},
+		"":                {},
+	} {
+		want.Raw = raw
+		if got := ParseSnapshot(raw); got.Raw != want.Raw || got.Date != want.Date || got.Source != want.Source {
+			t.Errorf("ParseSnapshot(%q) = %+v, want %+v", raw, got, want)
+		}
+	}
+}
+
+func TestSnapshotMatches(t *testing.T) {
+	s := ParseSnapshot("epic_2024-03-01")
+	for _, name := range []string{"epic_2024-03-01", "2024-03-01"} {
+		if !s.Matches(name) {
+			t.Errorf("%s does not match %q", s.Raw, name)
+		}
+	}
+	for _, name := range []string{"20240301", "epic", ""} {
+		if s.Matches(name) {
+			t.Errorf("%s matches %q", s.Raw, name)
+		}
+	}
+	if undated := ParseSnapshot("epic"); !undated.Matches("epic") || undated.Matches("") {
+		t.Errorf("undated snapshot %s should match only its own name", undated.Raw)
+	}
+}
@@ -0,0 +1,121 @@
+package tidydata
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	// Registers the pure-Go SQLite driver, which unlike a cgo binding keeps
+	// the client buildable with CGO_ENABLED=0 and cross-compilable.
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDriver is the database/sql driver used for tidydata databases.
+const sqliteDriver = "sqlite"
+
+// uriPathEscaper escapes the characters that end or escape the path of a
+// SQLite URI. Unlike url.PathEscape it leaves / alone.
+var uriPathEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+// sqliteURI returns the SQLite URI of the database file at path, escaping
+// characters such as ? and # that would otherwise end the path, followed
+// by query, if set.
+func sqliteURI(path, query string) string {
+	uri := "file:" + uriPathEscaper.Replace(path)
+	if query != "" {
+		uri += "?" + query
+	}
+	return uri
+}
+
+// OpenDB opens the tidydata database at path read-only.
+func OpenDB(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(sqliteDriver, sqliteURI(path, "mode=ro"))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("couldn't open %s: %v", path, err)
+	}
+	return db, nil
+}
+
+// quoteIdent quotes a table or column name for use in SQL.
+func quoteIdent(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// dbTables returns the set of tables in db.
+func dbTables(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type IN ('table', 'view')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables[name] = true
+	}
+	return tables, rows.Err()
+}
+
+// dbColumns returns the columns of a table in db, in order.
+func dbColumns(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", quoteIdent(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var (
+			cid       int
+			name, typ string
+			notNull   int
+			dflt      sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// dbQuickCheck returns the problems SQLite's quick_check finds in db, such
+// as pages lost to truncation, or none if it is intact.
+func dbQuickCheck(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("PRAGMA quick_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return nil, err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// dbRowCount returns the number of rows in a table in db.
+func dbRowCount(db *sql.DB, table string) (int64, error) {
+	var n int64
+	err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", quoteIdent(table))).Scan(&n)
+	return n, err
+}
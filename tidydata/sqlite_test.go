@@ -0,0 +1,65 @@
+package tidydata
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSqliteURI(t *testing.T) {
+	for path, want := range map[string]string{
+		"/data/ccga.sqlite":   "file:/data/ccga.sqlite",
+		"/data/a?b#c.sqlite":  "file:/data/a%3fb%23c.sqlite",
+		"/data/100%/x.sqlite": "file:/data/100%25/x.sqlite",
+	} {
+		if got := sqliteURI(path, ""); got != want {
+			t.Errorf("sqliteURI(%q) = %q, want %q", path, got, want)
+		}
+	}
+	if got, want := sqliteURI("/data/x.sqlite", "mode=ro"), "file:/data/x.sqlite?mode=ro"; got != want {
+		t.Errorf("sqliteURI with query = %q, want %q", got, want)
+	}
+}
+
+// TestOpenDBPath opens a database whose path has a directory separator and
+// characters that are special in URIs.
+func TestOpenDBPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "a?b#c"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "a?b#c", "tidyset.sqlite")
+	db, err := sql.Open(sqliteDriver, sqliteURI(path, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE patients (id INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("the database was not created at %s: %v", path, err)
+	}
+
+	db, err = OpenDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tables, err := dbTables(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tables["patients"] {
+		t.Errorf("tables of %s are %v, want patients", path, tables)
+	}
+	if _, err := db.Exec(`CREATE TABLE other (id INTEGER)`); err == nil {
+		t.Error("OpenDB opened the database writable")
+	}
+}
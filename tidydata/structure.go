@@ -0,0 +1,121 @@
+package tidydata
+
+import (
+	"sort"
+
+	"v.io/v23/context"
+)
+
+// TableStructure is the server-side description of a single table.
+type TableStructure struct {
+	Name    string   `json:"name"`
+	NumRows int64    `json:"num_rows"`
+	Columns []string `json:"columns"`
+}
+
+// TablesetStructure is the server-side description of a tableset and its
+// tables, keyed by table name.
+type TablesetStructure struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Tables      map[string]*TableStructure `json:"tables"`
+}
+
+// FilterStructure is the server-side description of a filter.
+type FilterStructure struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	QueryString string `json:"query_string"`
+}
+
+// VersionStructure is everything the server reports about the structure of
+// a dataset version: its tablesets, their tables and columns, and its
+// filters.
+type VersionStructure struct {
+	Dataset     string                        `json:"dataset"`
+	Version     string                        `json:"version"`
+	Description string                        `json:"description"`
+	Tablesets   map[string]*TablesetStructure `json:"tablesets"`
+	Filters     map[string]*FilterStructure   `json:"filters"`
+}
+
+// DescribeStructure walks every tableset, table and filter of a dataset
+// version.
+func (c *Client) DescribeStructure(ctx *context.T, dataset, version string) (*VersionStructure, error) {
+	description, err := c.DescribeVersion(ctx, dataset, version)
+	if err != nil {
+		return nil, err
+	}
+	vs := &VersionStructure{
+		Dataset:     dataset,
+		Version:     version,
+		Description: description,
+		Tablesets:   map[string]*TablesetStructure{},
+		Filters:     map[string]*FilterStructure{},
+	}
+	tablesets, err := c.ListTablesets(ctx, dataset, version)
+	if err != nil {
+		return nil, err
+	}
+	for _, ts := range tablesets {
+		tsDescription, err := c.DescribeTableset(ctx, dataset, version, ts)
+		if err != nil {
+			return nil, err
+		}
+		tss := &TablesetStructure{Name: ts, Description: tsDescription, Tables: map[string]*TableStructure{}}
+		tables, err := c.ListTables(ctx, dataset, version, ts)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range tables {
+			info, err := c.DescribeTable(ctx, dataset, version, ts, name)
+			if err != nil {
+				return nil, err
+			}
+			tss.Tables[name] = &TableStructure{Name: name, NumRows: info.NumRows, Columns: info.Columns}
+		}
+		vs.Tablesets[ts] = tss
+	}
+	filters, err := c.ListFilters(ctx, dataset, version)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range filters {
+		f, err := c.DescribeFilter(ctx, dataset, version, name)
+		if err != nil {
+			return nil, err
+		}
+		vs.Filters[name] = &FilterStructure{Name: name, Description: f.Description, QueryString: f.QueryString}
+	}
+	return vs, nil
+}
+
+// TablesetNames returns the names of the version's tablesets in sorted order.
+func (vs *VersionStructure) TablesetNames() []string {
+	var names []string
+	for name := range vs.Tablesets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FilterNames returns the names of the version's filters in sorted order.
+func (vs *VersionStructure) FilterNames() []string {
+	var names []string
+	for name := range vs.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TableNames returns the names of the tableset's tables in sorted order.
+func (ts *TablesetStructure) TableNames() []string {
+	var names []string
+	for name := range ts.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,271 @@
+package tidydata
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"v.io/v23/context"
+)
+
+// SubsetRequest describes a deterministic sample of a tidyset.
+type SubsetRequest struct {
+	Dataset  string
+	Version  string
+	Tableset string
+	// Path is the local tidyset database to sample. If empty, the tidyset
+	// is fetched into the local cache.
+	Path string
+	// Output is the path of the sampled database.
+	Output string
+	// Key is the column identifying a subject. Every row of every table
+	// with a sampled key is kept; tables without the column are copied in
+	// full.
+	Key string
+	// Fraction is the fraction of keys sampled, in (0, 1].
+	Fraction float64
+	// Seed selects the sample; the same seed always selects the same keys.
+	Seed int64
+}
+
+// SubsetTable records how a table of a subset was derived.
+type SubsetTable struct {
+	Name       string `json:"name"`
+	SourceRows int64  `json:"source_rows"`
+	Rows       int64  `json:"rows"`
+	// Sampled is false for tables without the key column, which are
+	// copied in full.
+	Sampled bool `json:"sampled"`
+}
+
+// SubsetProvenance records how a subset database was derived. It is written
+// next to the database, with a .provenance.json suffix.
+type SubsetProvenance struct {
+	Dataset      string        `json:"dataset"`
+	Version      string        `json:"version"`
+	Tableset     string        `json:"tableset"`
+	Source       string        `json:"source"`
+	SourceSHA256 string        `json:"source_sha256"`
+	Output       string        `json:"output"`
+	Key          string        `json:"key"`
+	Fraction     float64       `json:"fraction"`
+	Seed         int64         `json:"seed"`
+	Keys         int64         `json:"keys"`
+	Created      time.Time     `json:"created"`
+	Tables       []SubsetTable `json:"tables"`
+}
+
+// ProvenancePath returns the path of the provenance record of the subset
+// database at path.
+func ProvenancePath(path string) string {
+	return path + ".provenance.json"
+}
+
+// sampleKey reports whether a key is in the sample. The decision depends
+// only on the seed and the key's text, so a key is kept in every table or
+// in none.
+func sampleKey(seed int64, key interface{}, fraction float64) bool {
+	var text string
+	switch key := key.(type) {
+	case []byte:
+		text = string(key)
+	default:
+		text = fmt.Sprint(key)
+	}
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, seed)
+	h.Write([]byte(text))
+	sum := h.Sum(nil)
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11)/(1<<53) < fraction
+}
+
+// schemaObject is a table, index or view of a sqlite database.
+type schemaObject struct {
+	typ, name, sql string
+}
+
+func dbSchema(db *sql.DB) ([]schemaObject, error) {
+	rows, err := db.Query(`SELECT type, name, sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite\_%' ESCAPE '\' ORDER BY CASE type WHEN 'table' THEN 0 ELSE 1 END, rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var objs []schemaObject
+	for rows.Next() {
+		var o schemaObject
+		if err := rows.Scan(&o.typ, &o.name, &o.sql); err != nil {
+			return nil, err
+		}
+		objs = append(objs, o)
+	}
+	return objs, rows.Err()
+}
+
+// copyTable streams the rows of table from src to dst, keeping those
+// selected by keep, and returns the number of rows read and written.
+func copyTable(src *sql.DB, dst *sql.Tx, table string, columns []string, keep func(row []interface{}) bool) (read, written int64, err error) {
+	var quoted, params []string
+	for _, col := range columns {
+		quoted = append(quoted, quoteIdent(col))
+		params = append(params, "?")
+	}
+	insert, err := dst.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdent(table), strings.Join(quoted, ", "), strings.Join(params, ", ")))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer insert.Close()
+	rows, err := src.Query(fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), quoteIdent(table)))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return read, written, err
+		}
+		read++
+		if !keep(values) {
+			continue
+		}
+		if _, err := insert.Exec(values...); err != nil {
+			return read, written, err
+		}
+		written++
+	}
+	return read, written, rows.Err()
+}
+
+// Subset writes a database containing every row of the tidyset belonging to
+// a deterministic sample of the values of req.Key, together with its
+// provenance record.
+func (c *Client) Subset(ctx *context.T, req SubsetRequest) (*SubsetProvenance, error) {
+	if req.Fraction <= 0 || req.Fraction > 1 {
+		return nil, fmt.Errorf("fraction %v must be in (0, 1]", req.Fraction)
+	}
+	if req.Key == "" {
+		return nil, fmt.Errorf("a key column is required")
+	}
+	if req.Output == "" {
+		return nil, fmt.Errorf("an output path is required")
+	}
+	if req.Path == "" {
+		fetched, err := c.Tidyset(ctx, TidysetRequest{Dataset: req.Dataset, Version: req.Version, Tableset: req.Tableset})
+		if err != nil {
+			return nil, err
+		}
+		req.Path = fetched.Path
+	}
+	srcSum, _, err := FileSHA256(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	src, err := OpenDB(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	schema, err := dbSchema(src)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp := req.Output + ".tmp"
+	os.Remove(tmp)
+	defer os.Remove(tmp)
+	dst, err := sql.Open(sqliteDriver, sqliteURI(tmp, ""))
+	if err != nil {
+		return nil, err
+	}
+	defer dst.Close()
+	tx, err := dst.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	prov := &SubsetProvenance{
+		Dataset:      req.Dataset,
+		Version:      req.Version,
+		Tableset:     req.Tableset,
+		Source:       req.Path,
+		SourceSHA256: srcSum,
+		Output:       req.Output,
+		Key:          req.Key,
+		Fraction:     req.Fraction,
+		Seed:         req.Seed,
+		Created:      time.Now().UTC(),
+	}
+	keys := map[string]bool{}
+	// Indexes and views are created after the data is loaded.
+	for _, obj := range schema {
+		if obj.typ != "table" {
+			continue
+		}
+		if _, err := tx.Exec(obj.sql); err != nil {
+			return nil, fmt.Errorf("couldn't create %s: %v", obj.name, err)
+		}
+		columns, err := dbColumns(src, obj.name)
+		if err != nil {
+			return nil, err
+		}
+		key := -1
+		for i, col := range columns {
+			if col == req.Key {
+				key = i
+			}
+		}
+		keep := func(row []interface{}) bool { return true }
+		if key >= 0 {
+			keep = func(row []interface{}) bool {
+				if row[key] == nil || !sampleKey(req.Seed, row[key], req.Fraction) {
+					return false
+				}
+				keys[fmt.Sprint(row[key])] = true
+				return true
+			}
+		}
+		read, written, err := copyTable(src, tx, obj.name, columns, keep)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't copy %s: %v", obj.name, err)
+		}
+		prov.Tables = append(prov.Tables, SubsetTable{Name: obj.name, SourceRows: read, Rows: written, Sampled: key >= 0})
+	}
+	sampled := false
+	for _, t := range prov.Tables {
+		sampled = sampled || t.Sampled
+	}
+	if !sampled {
+		return nil, fmt.Errorf("no table of %s has a %s column", req.Path, req.Key)
+	}
+	for _, obj := range schema {
+		if obj.typ == "table" {
+			continue
+		}
+		if _, err := tx.Exec(obj.sql); err != nil {
+			return nil, fmt.Errorf("couldn't create %s: %v", obj.name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if err := dst.Close(); err != nil {
+		return nil, err
+	}
+	prov.Keys = int64(len(keys))
+	if err := os.Rename(tmp, req.Output); err != nil {
+		return nil, err
+	}
+	if err := writeJSONFile(ProvenancePath(req.Output), prov); err != nil {
+		return nil, err
+	}
+	return prov, nil
+}
@@ -0,0 +1,46 @@
+package tidydata
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDBSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "subset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := sql.Open(sqliteDriver, sqliteURI(filepath.Join(dir, "tidyset.sqlite"), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		// AUTOINCREMENT makes sqlite create its internal sqlite_sequence
+		// table, which must be skipped, unlike sqliteX.
+		`CREATE TABLE patients (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)`,
+		`CREATE TABLE sqliteX (id INTEGER)`,
+		`CREATE INDEX patients_name ON patients (name)`,
+		`CREATE VIEW named AS SELECT * FROM patients WHERE name IS NOT NULL`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	objs, err := dbSchema(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, o := range objs {
+		got = append(got, o.typ+" "+o.name)
+	}
+	if want := []string{"table patients", "table sqliteX", "index patients_name", "view named"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dbSchema = %q, want %q", got, want)
+	}
+}
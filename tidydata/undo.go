@@ -0,0 +1,153 @@
+package tidydata
+
+import (
+	"fmt"
+
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+)
+
+// undoable reports whether an operation can be reverted by Undo.
+func undoable(op string) bool {
+	switch op {
+	case OpUpdateState, OpUpdateDescription, OpAddAlias, OpUpdateAlias, OpRemoveAlias:
+		return true
+	}
+	return false
+}
+
+// findUndoTarget returns the record of the operation with the given ID or,
+// if id is empty, of the most recent successful operation that can be
+// undone and has not been.
+func findUndoTarget(records []AuditRecord, id string) (*AuditRecord, error) {
+	undone := map[string]bool{}
+	for _, r := range records {
+		if r.OK() && r.Undoes != "" {
+			undone[r.Undoes] = true
+		}
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if id != "" {
+			if r.ID != id {
+				continue
+			}
+			if !r.OK() {
+				return nil, fmt.Errorf("operation %s failed, so there is nothing to undo", id)
+			}
+			if undone[id] {
+				return nil, fmt.Errorf("operation %s has already been undone", id)
+			}
+			return &r, nil
+		}
+		if r.OK() && r.Undoes == "" && undoable(r.Operation) && !undone[r.ID] {
+			return &r, nil
+		}
+	}
+	if id != "" {
+		return nil, fmt.Errorf("no operation %s in the audit log", id)
+	}
+	return nil, fmt.Errorf("no operation to undo in the audit log")
+}
+
+// Undo reverts the administrative operation with the given ID in the
+// client's audit log or, if id is empty, the most recent operation that
+// has not been undone. It refuses if the server no longer reflects the
+// operation, for example because the alias has been moved again since, or
+// if the prior value was not captured. The revert is itself recorded in
+// the audit log. Undo returns the record of the reverted operation.
+func (c *Client) Undo(ctx *context.T, id string) (*AuditRecord, error) {
+	if c.audit == nil {
+		return nil, fmt.Errorf("the client keeps no audit log")
+	}
+	records, err := c.audit.Query(AuditQuery{})
+	if err != nil {
+		return nil, err
+	}
+	r, err := findUndoTarget(records, id)
+	if err != nil {
+		return nil, err
+	}
+	if !undoable(r.Operation) {
+		return nil, fmt.Errorf("%s operations can't be undone", r.Operation)
+	}
+	if r.Prior == nil {
+		return nil, fmt.Errorf("the prior value of operation %s was not captured: %s", r.ID, r.PriorError)
+	}
+	changed := func(what string) error {
+		return fmt.Errorf("refusing to undo operation %s: %s has changed since", r.ID, what)
+	}
+	snap, err := c.Snapshot(ctx, r.Dataset)
+	if err != nil {
+		return nil, err
+	}
+	undo := AuditRecord{Dataset: r.Dataset, Undoes: r.ID}
+	var do func() error
+	switch a := r.Args; r.Operation {
+	case OpUpdateState:
+		dataset, version, state := a[0], a[1], a[2]
+		if snap.States[version].String() != state {
+			return nil, changed("the state of " + version)
+		}
+		if r.Prior.State == "" {
+			return nil, fmt.Errorf("%s had no prior state", version)
+		}
+		prior, err := vdl.StateFromString(r.Prior.State)
+		if err != nil {
+			return nil, err
+		}
+		undo.Operation, undo.Args = OpUpdateState, []string{dataset, version, r.Prior.State}
+		do = func() error { return c.tidy.UpdateVersionState(dataset, version, prior) }
+	case OpUpdateDescription:
+		dataset, version, description := a[0], a[1], a[2]
+		current, err := c.VersionDescription(ctx, dataset, version)
+		if err != nil {
+			return nil, err
+		}
+		if current != description {
+			return nil, changed("the description of " + version)
+		}
+		undo.Operation, undo.Args = OpUpdateDescription, []string{dataset, version, r.Prior.Description}
+		do = func() error { return c.tidy.UpdateVersionDescription(dataset, version, r.Prior.Description) }
+	case OpAddAlias:
+		dataset, version, alias := a[0], a[1], a[2]
+		if snap.Aliases[alias] != version {
+			return nil, changed("alias " + alias)
+		}
+		if prior := r.Prior.Aliases[alias]; prior != "" {
+			undo.Operation, undo.Args = OpAddAlias, []string{dataset, prior, alias}
+			do = func() error { return c.tidy.AddVersionAlias(dataset, prior, alias) }
+		} else {
+			undo.Operation, undo.Args = OpRemoveAlias, []string{dataset, alias}
+			do = func() error { return c.tidy.RemoveVersionAlias(dataset, alias) }
+		}
+	case OpUpdateAlias:
+		dataset, alias, newAlias := a[0], a[1], a[2]
+		if snap.Aliases[alias] != "" || snap.Aliases[newAlias] != r.Prior.Aliases[alias] {
+			return nil, changed("alias " + newAlias)
+		}
+		if r.Prior.Aliases[newAlias] != "" {
+			return nil, fmt.Errorf("can't undo operation %s: it replaced alias %s, which pointed to %s", r.ID, newAlias, r.Prior.Aliases[newAlias])
+		}
+		undo.Operation, undo.Args = OpUpdateAlias, []string{dataset, newAlias, alias}
+		do = func() error { return c.tidy.UpdateVersionAlias(dataset, newAlias, alias) }
+	case OpRemoveAlias:
+		dataset, alias := a[0], a[1]
+		if snap.Aliases[alias] != "" {
+			return nil, changed("alias " + alias)
+		}
+		prior := r.Prior.Aliases[alias]
+		if prior == "" {
+			return nil, fmt.Errorf("alias %s did not exist before operation %s", alias, r.ID)
+		}
+		undo.Operation, undo.Args = OpAddAlias, []string{dataset, prior, alias}
+		do = func() error { return c.tidy.AddVersionAlias(dataset, prior, alias) }
+	}
+	if err := c.checkProtection(ctx, &undo); err != nil {
+		return nil, err
+	}
+	if err := c.checkApproval(undo); err != nil {
+		return nil, err
+	}
+	return r, c.auditedRecord(ctx, undo, c.priorFor(ctx, undo.Operation, undo.Args), do)
+}
@@ -0,0 +1,54 @@
+package tidydata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindUndoTarget(t *testing.T) {
+	log := []AuditRecord{
+		{ID: "1", Operation: OpAddAlias},
+		{ID: "2", Operation: OpUpdateState},
+		{ID: "3", Operation: OpUpdateAlias, Error: "permission denied"},
+		{ID: "4", Operation: OpRemoveAlias, Undoes: "2"},
+		{ID: "5", Operation: OpAddVersion},
+		{ID: "6", Operation: OpUpdateDescription},
+	}
+
+	// Repeated undos walk back through the log, skipping failed
+	// operations, versions, which can't be removed, and operations that
+	// were already undone.
+	for _, want := range []string{"6", "1"} {
+		r, err := findUndoTarget(log, "")
+		if err != nil {
+			t.Fatalf("findUndoTarget after %s: %v", log[len(log)-1].ID, err)
+		}
+		if r.ID != want {
+			t.Fatalf("findUndoTarget after %s = %s, want %s", log[len(log)-1].ID, r.ID, want)
+		}
+		log = append(log, AuditRecord{ID: "undo" + want, Operation: r.Operation, Undoes: want})
+	}
+	if r, err := findUndoTarget(log, ""); err == nil {
+		t.Errorf("findUndoTarget with everything undone = %s, want an error", r.ID)
+	}
+	if _, err := findUndoTarget(nil, ""); err == nil {
+		t.Error("findUndoTarget of an empty log succeeded")
+	}
+
+	// An operation named by ID is returned even if it is older than others
+	// or can't be undone, which Undo reports itself.
+	for _, id := range []string{"5", "6"} {
+		if r, err := findUndoTarget(log[:6], id); err != nil || r.ID != id {
+			t.Errorf("findUndoTarget(%s) = %v, %v", id, r, err)
+		}
+	}
+	for id, want := range map[string]string{
+		"2": "already been undone",
+		"3": "failed",
+		"8": "no operation 8",
+	} {
+		if _, err := findUndoTarget(log, id); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("findUndoTarget(%s) error = %v, want one containing %q", id, err, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package tidydata
+
+import (
+	"fmt"
+
+	"v.io/v23/context"
+)
+
+// ValidateRequest identifies a local tidyset database and the server-side
+// tidyset it should match.
+type ValidateRequest struct {
+	Dataset  string
+	Version  string
+	Tableset string
+	// Filters that were applied when the database was fetched. The server
+	// reports row counts before filtering only, so filtered tables can
+	// only be checked not to exceed them.
+	Filters []string
+	// Path is the local database.
+	Path string
+}
+
+// DatabaseMismatch is the Table of mismatches concerning the database as a
+// whole rather than one of its tables.
+const DatabaseMismatch = "(database)"
+
+// Mismatch is a difference between a local database and its server-side
+// description.
+type Mismatch struct {
+	Table   string `json:"table"`
+	Problem string `json:"problem"`
+}
+
+// ValidationReport is the result of validating a local database.
+type ValidationReport struct {
+	Path       string     `json:"path"`
+	Tables     int        `json:"tables"`
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// OK reports whether no mismatches were found.
+func (r *ValidationReport) OK() bool {
+	return len(r.Mismatches) == 0
+}
+
+// Validate checks that the local database at req.Path passes SQLite's
+// quick_check, which detects truncated and corrupted files, that it
+// contains every table the server lists for the tableset, that each table
+// has the columns the server describes, and that row counts match the
+// server's, or do not exceed them if filters were applied.
+func (c *Client) Validate(ctx *context.T, req ValidateRequest) (*ValidationReport, error) {
+	db, err := OpenDB(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	local, err := dbTables(db)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read tables of %s: %v", req.Path, err)
+	}
+	tables, err := c.ListTables(ctx, req.Dataset, req.Version, req.Tableset)
+	if err != nil {
+		return nil, err
+	}
+	report := &ValidationReport{Path: req.Path, Tables: len(tables)}
+	mismatch := func(table, format string, args ...interface{}) {
+		report.Mismatches = append(report.Mismatches, Mismatch{Table: table, Problem: fmt.Sprintf(format, args...)})
+	}
+	problems, err := dbQuickCheck(db)
+	if err != nil {
+		mismatch(DatabaseMismatch, "integrity check failed: %v", err)
+	}
+	for _, p := range problems {
+		mismatch(DatabaseMismatch, "%s", p)
+	}
+	for _, table := range tables {
+		if !local[table] {
+			mismatch(table, "table is missing")
+			continue
+		}
+		desc, err := c.DescribeTable(ctx, req.Dataset, req.Version, req.Tableset, table)
+		if err != nil {
+			return nil, err
+		}
+		columns, err := dbColumns(db, table)
+		if err != nil {
+			mismatch(table, "couldn't read columns: %v", err)
+			continue
+		}
+		missing, extra := diffNames(columns, desc.Columns)
+		for _, col := range missing {
+			mismatch(table, "column %s is missing", col)
+		}
+		for _, col := range extra {
+			mismatch(table, "unexpected column %s", col)
+		}
+		n, err := dbRowCount(db, table)
+		if err != nil {
+			mismatch(table, "couldn't count rows: %v", err)
+			continue
+		}
+		switch {
+		case len(req.Filters) == 0 && n != desc.NumRows:
+			mismatch(table, "has %d rows, expected %d", n, desc.NumRows)
+		case len(req.Filters) > 0 && n > desc.NumRows:
+			mismatch(table, "has %d rows, more than the %d expected before filtering", n, desc.NumRows)
+		}
+	}
+	return report, nil
+}
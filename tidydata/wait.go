@@ -0,0 +1,96 @@
+package tidydata
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+)
+
+// IsFailedState reports whether state is the failed publish state.
+func IsFailedState(state vdl.State) bool {
+	return state == vdl.StateFailed
+}
+
+// lowestState returns the minimum state at which listing the versions of a
+// dataset returns all of them, including failed ones.
+func lowestState() vdl.State {
+	if vdl.StateFailed < vdl.StateGenerating {
+		return vdl.StateFailed
+	}
+	return vdl.StateGenerating
+}
+
+// VersionState returns the publish state of a version, or false if the
+// dataset has no such version. The tidy server has no call that returns the
+// state of a single version, so every version of the dataset, in any state,
+// is listed.
+func (c *Client) VersionState(ctx *context.T, dataset, version string) (vdl.State, bool, error) {
+	entries, err := c.versionsAt(dataset, lowestState())
+	if err != nil {
+		return 0, false, err
+	}
+	for _, e := range entries {
+		if e.Version == version {
+			return e.State, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// WaitRequest describes the state a version is waited for.
+type WaitRequest struct {
+	Dataset string
+	Version string
+	// State is the minimum publish state to wait for.
+	State vdl.State
+	// Interval is how often the server is polled.
+	Interval time.Duration
+	// Progress, if set, is called with the version's state, or "missing",
+	// each time it changes, and with any poll failure.
+	Progress func(state string, err error)
+}
+
+// WaitForState blocks until req.Version reaches at least req.State, using
+// the same comparison as ListVersions, and returns the state reached. It
+// returns an error if the version fails or ctx is done first.
+func (c *Client) WaitForState(ctx *context.T, req WaitRequest) (vdl.State, error) {
+	if req.Interval <= 0 {
+		return req.State, errors.New("wait interval must be positive")
+	}
+	progress := req.Progress
+	if progress == nil {
+		progress = func(string, error) {}
+	}
+	ticker := time.NewTicker(req.Interval)
+	defer ticker.Stop()
+	last := ""
+	for {
+		state, ok, err := c.VersionState(ctx, req.Dataset, req.Version)
+		if err != nil {
+			progress(last, err)
+		} else {
+			current := "missing"
+			if ok {
+				current = state.String()
+			}
+			if current != last {
+				progress(current, nil)
+				last = current
+			}
+			switch {
+			case ok && IsFailedState(state):
+				return state, fmt.Errorf("%s %s is in state %s", req.Dataset, req.Version, state)
+			case ok && state >= req.State:
+				return state, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return req.State, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package tidydata
+
+import (
+	"testing"
+
+	tidy "grail.com/tidy/vanadium/client"
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+)
+
+// versionsServer is a tidy client whose datasets have fixed versions. Only
+// ListVersionsAt is implemented.
+type versionsServer struct {
+	tidy.Client
+	versions []vdl.VersionState
+}
+
+func (s *versionsServer) ListVersionsAt(dataset string, state vdl.State) ([]vdl.VersionState, error) {
+	var versions []vdl.VersionState
+	for _, v := range s.versions {
+		if v.State >= state {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+func TestVersionState(t *testing.T) {
+	c := NewClientFrom("", &versionsServer{versions: []vdl.VersionState{
+		{Version: "v1", State: vdl.StatePublished},
+		{Version: "v2", State: vdl.StateFailed},
+		{Version: "v3", State: vdl.StateGenerating},
+	}})
+	for version, want := range map[string]vdl.State{"v1": vdl.StatePublished, "v2": vdl.StateFailed, "v3": vdl.StateGenerating} {
+		state, ok, err := c.VersionState(nil, "d", version)
+		if err != nil || !ok || state != want {
+			t.Errorf("VersionState(%s) = %v, %v, %v, want %v", version, state, ok, err, want)
+		}
+	}
+	if _, ok, err := c.VersionState(nil, "d", "v4"); ok || err != nil {
+		t.Errorf("VersionState of a missing version = %v, %v, want not found", ok, err)
+	}
+	if !IsFailedState(vdl.StateFailed) || IsFailedState(vdl.StatePublished) {
+		t.Error("IsFailedState does not identify exactly the failed state")
+	}
+}
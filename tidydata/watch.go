@@ -0,0 +1,251 @@
+package tidydata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+)
+
+// Event describes a change to a dataset observed by Watch.
+type Event struct {
+	Type       string    `json:"type"`
+	Dataset    string    `json:"dataset"`
+	Version    string    `json:"version,omitempty"`
+	Alias      string    `json:"alias,omitempty"`
+	OldVersion string    `json:"old_version,omitempty"`
+	OldState   string    `json:"old_state,omitempty"`
+	State      string    `json:"state,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Event types.
+const (
+	EventNewVersion   = "new_version"
+	EventStateChange  = "state_change"
+	EventAliasAdded   = "alias_added"
+	EventAliasMoved   = "alias_moved"
+	EventAliasRemoved = "alias_removed"
+)
+
+// DatasetSnapshot is the state of a dataset's versions and aliases at a
+// point in time.
+type DatasetSnapshot struct {
+	// States maps each version to its publish state.
+	States map[string]vdl.State
+	// Aliases maps each alias to the version it points to.
+	Aliases map[string]string
+}
+
+// Snapshot returns the current versions, states and aliases of a dataset.
+func (c *Client) Snapshot(ctx *context.T, dataset string) (*DatasetSnapshot, error) {
+	snap := &DatasetSnapshot{States: map[string]vdl.State{}, Aliases: map[string]string{}}
+	versions, err := c.ListVersions(ctx, VersionsRequest{Dataset: dataset, MinState: lowestState()})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		snap.States[v.Version] = v.State
+	}
+	aliased, err := c.ListVersions(ctx, VersionsRequest{Dataset: dataset, MinState: lowestState(), WithAlias: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range aliased {
+		if v.Alias != "" {
+			snap.Aliases[v.Alias] = v.Version
+		}
+		if _, ok := snap.States[v.Version]; !ok {
+			snap.States[v.Version] = v.State
+		}
+	}
+	return snap, nil
+}
+
+// DiffSnapshots returns the events that transform prev into cur. If alias
+// is non-empty, only events concerning that alias, or the version it points
+// to, are returned.
+func DiffSnapshots(dataset, alias string, prev, cur *DatasetSnapshot, now time.Time) []Event {
+	var events []Event
+	var versions []string
+	for v := range cur.States {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	for _, v := range versions {
+		if alias != "" && cur.Aliases[alias] != v {
+			continue
+		}
+		state := cur.States[v]
+		old, ok := prev.States[v]
+		switch {
+		case !ok:
+			events = append(events, Event{Type: EventNewVersion, Dataset: dataset, Version: v, State: state.String(), Time: now})
+		case old != state:
+			events = append(events, Event{Type: EventStateChange, Dataset: dataset, Version: v, OldState: old.String(), State: state.String(), Time: now})
+		}
+	}
+	var aliases []string
+	for a := range cur.Aliases {
+		aliases = append(aliases, a)
+	}
+	for a := range prev.Aliases {
+		if _, ok := cur.Aliases[a]; !ok {
+			aliases = append(aliases, a)
+		}
+	}
+	sort.Strings(aliases)
+	for _, a := range aliases {
+		if alias != "" && a != alias {
+			continue
+		}
+		v, ok := cur.Aliases[a]
+		old, wasOK := prev.Aliases[a]
+		switch {
+		case ok && !wasOK:
+			events = append(events, Event{Type: EventAliasAdded, Dataset: dataset, Alias: a, Version: v, Time: now})
+		case !ok && wasOK:
+			events = append(events, Event{Type: EventAliasRemoved, Dataset: dataset, Alias: a, OldVersion: old, Time: now})
+		case ok && v != old:
+			events = append(events, Event{Type: EventAliasMoved, Dataset: dataset, Alias: a, OldVersion: old, Version: v, Time: now})
+		}
+	}
+	return events
+}
+
+// WatchRequest describes what Watch observes.
+type WatchRequest struct {
+	Dataset string
+	// Alias, if set, restricts events to that alias and the version it
+	// points to.
+	Alias string
+	// Interval is how often the server is polled.
+	Interval time.Duration
+	// Log, if set, receives poll failures and hook errors.
+	Log io.Writer
+}
+
+// Watch polls a dataset until ctx is done, calling each hook for every
+// event observed. The first poll establishes the baseline and produces no
+// events. Failed polls and hooks are reported to req.Log and do not end
+// the watch.
+func (c *Client) Watch(ctx *context.T, req WatchRequest, hooks ...func(Event) error) error {
+	if req.Interval <= 0 {
+		return fmt.Errorf("watch interval must be positive")
+	}
+	logf := func(format string, args ...interface{}) {
+		if req.Log != nil {
+			fmt.Fprintf(req.Log, format, args...)
+		}
+	}
+	prev, err := c.Snapshot(ctx, req.Dataset)
+	if err != nil {
+		return err
+	}
+	if req.Alias != "" {
+		if _, ok := prev.Aliases[req.Alias]; !ok {
+			logf("alias %s does not currently exist in %s\n", req.Alias, req.Dataset)
+		}
+	}
+	ticker := time.NewTicker(req.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			cur, err := c.Snapshot(ctx, req.Dataset)
+			if err != nil {
+				logf("poll failed: %v\n", err)
+				continue
+			}
+			for _, event := range DiffSnapshots(req.Dataset, req.Alias, prev, cur, now) {
+				for _, hook := range hooks {
+					if err := hook(event); err != nil {
+						logf("%v\n", err)
+					}
+				}
+			}
+			prev = cur
+		}
+	}
+}
+
+// PrintHook returns a hook that writes each event to w as a line of JSON.
+func PrintHook(w io.Writer) func(Event) error {
+	return func(event Event) error {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", payload)
+		return err
+	}
+}
+
+// ExecHook returns a hook that runs a shell command for each event, with
+// the JSON event on its standard input and the event fields in
+// TIDY_EVENT_* environment variables.
+func ExecHook(command string, stdout, stderr io.Writer) func(Event) error {
+	return func(event Event) error {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command("/bin/sh", "-c", command)
+		cmd.Stdin = bytes.NewReader(payload)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		cmd.Env = append(os.Environ(),
+			"TIDY_EVENT_TYPE="+event.Type,
+			"TIDY_EVENT_DATASET="+event.Dataset,
+			"TIDY_EVENT_VERSION="+event.Version,
+			"TIDY_EVENT_ALIAS="+event.Alias,
+			"TIDY_EVENT_OLD_VERSION="+event.OldVersion,
+			"TIDY_EVENT_OLD_STATE="+event.OldState,
+			"TIDY_EVENT_STATE="+event.State,
+		)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("hook %q failed: %v", command, err)
+		}
+		return nil
+	}
+}
+
+// WebhookTimeout bounds each webhook request, so that an unresponsive
+// endpoint cannot stall a watch.
+const WebhookTimeout = 30 * time.Second
+
+// WebhookHook returns a hook that POSTs each JSON event to url. Requests
+// are abandoned after WebhookTimeout or once ctx is done.
+func WebhookHook(ctx *context.T, url string) func(Event) error {
+	client := &http.Client{Timeout: WebhookTimeout}
+	return func(event Event) error {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("webhook %s failed: %v", url, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("webhook %s failed: %v", url, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook %s failed: %s", url, resp.Status)
+		}
+		return nil
+	}
+}
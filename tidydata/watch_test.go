@@ -0,0 +1,77 @@
+package tidydata
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	tested, err := vdl.StateFromString("tested")
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err := vdl.StateFromString("published")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	prev := &DatasetSnapshot{
+		States:  map[string]vdl.State{"v1": published, "v2": tested},
+		Aliases: map[string]string{"latest": "v1", "old": "v1", "gone": "v1"},
+	}
+	cur := &DatasetSnapshot{
+		States:  map[string]vdl.State{"v1": published, "v2": published, "v3": tested},
+		Aliases: map[string]string{"latest": "v2", "old": "v1", "beta": "v3"},
+	}
+	for _, test := range []struct {
+		alias string
+		want  []Event
+	}{
+		{"", []Event{
+			{Type: EventStateChange, Dataset: "d", Version: "v2", OldState: tested.String(), State: published.String(), Time: now},
+			{Type: EventNewVersion, Dataset: "d", Version: "v3", State: tested.String(), Time: now},
+			{Type: EventAliasAdded, Dataset: "d", Alias: "beta", Version: "v3", Time: now},
+			{Type: EventAliasRemoved, Dataset: "d", Alias: "gone", OldVersion: "v1", Time: now},
+			{Type: EventAliasMoved, Dataset: "d", Alias: "latest", OldVersion: "v1", Version: "v2", Time: now},
+		}},
+		{"latest", []Event{
+			{Type: EventStateChange, Dataset: "d", Version: "v2", OldState: tested.String(), State: published.String(), Time: now},
+			{Type: EventAliasMoved, Dataset: "d", Alias: "latest", OldVersion: "v1", Version: "v2", Time: now},
+		}},
+		{"gone", []Event{
+			{Type: EventAliasRemoved, Dataset: "d", Alias: "gone", OldVersion: "v1", Time: now},
+		}},
+		{"old", nil},
+	} {
+		if got := DiffSnapshots("d", test.alias, prev, cur, now); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("DiffSnapshots(alias %q) = %+v, want %+v", test.alias, got, test.want)
+		}
+	}
+	if got := DiffSnapshots("d", "", cur, cur, now); got != nil {
+		t.Errorf("DiffSnapshots of identical snapshots = %+v, want none", got)
+	}
+}
+
+func TestExecHook(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	hook := ExecHook(`echo "$TIDY_EVENT_TYPE $TIDY_EVENT_ALIAS $TIDY_EVENT_OLD_VERSION->$TIDY_EVENT_VERSION"; cat`, &stdout, &stderr)
+	event := Event{Type: EventAliasMoved, Dataset: "d", Alias: "latest", OldVersion: "v1", Version: "v2"}
+	if err := hook(event); err != nil {
+		t.Fatalf("hook failed: %v (%s)", err, stderr.String())
+	}
+	lines := strings.SplitN(stdout.String(), "\n", 2)
+	if want := "alias_moved latest v1->v2"; lines[0] != want {
+		t.Errorf("hook saw environment %q, want %q", lines[0], want)
+	}
+	if len(lines) < 2 || !strings.Contains(lines[1], `"alias":"latest"`) {
+		t.Errorf("hook did not receive the JSON event on stdin: %q", stdout.String())
+	}
+	if err := ExecHook("exit 3", &stdout, &stderr)(event); err == nil {
+		t.Error("a failing hook command did not return an error")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runUndo(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) > 1 {
+		return errors.New("need at most 1 argument: [operation-id]")
+	}
+	if err := setupAuditLog(); err != nil {
+		return err
+	}
+	if err := setupAliasPolicy(); err != nil {
+		return err
+	}
+	if err := setupApprovalStore(false); err != nil {
+		return err
+	}
+	id := ""
+	if len(args) == 1 {
+		id = args[0]
+	}
+	r, err := client.Undo(ctx, id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Stdout, "undid %s: %s %s\n", r.ID, r.Operation, strings.Join(r.Args, " "))
+	return nil
+}
+
+func cmdUndo() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner: v23cmd.RunnerFunc(runUndo),
+		Name:   "undo",
+		Short:  "undo an alias, state or description change",
+		Long: `Reverts an operation recorded in the local audit log, restoring the alias,
+publish state or description it changed to the value captured beforehand.
+Without an operation ID, reverts the most recent operation that has not been
+undone. Refuses if the server no longer reflects the operation, for example
+because the alias has been moved again since. Operation IDs are listed by the
+audit command. Reverting a change to a protected alias requires --force and
+a --reason, and reverting a version to published or above is refused, since
+that requires approval through version request-publish.`,
+		ArgsName: "[--force --reason reason] [operation-id]",
+	}
+	addProtectionFlags(cmd)
+	return cmd
+}
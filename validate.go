@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runValidate(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) != 3 && len(args) != 4 {
+		return errors.New("need 3 or 4 arguments: <dataset> <version> <tableset> [file]")
+	}
+	req := tidydata.ValidateRequest{
+		Dataset:  args[0],
+		Version:  args[1],
+		Tableset: args[2],
+	}
+	if len(filtersFlag) > 0 {
+		req.Filters = strings.Split(filtersFlag, ",")
+	}
+	if len(args) == 4 {
+		req.Path = args[3]
+	} else {
+		fetched, err := client.Tidyset(ctx, tidydata.TidysetRequest{
+			Dataset:  req.Dataset,
+			Version:  req.Version,
+			Tableset: req.Tableset,
+			Filters:  req.Filters,
+		})
+		if err != nil {
+			return err
+		}
+		req.Path = fetched.Path
+	}
+	report, err := client.Validate(ctx, req)
+	if err != nil {
+		return err
+	}
+	if formatFlag == "json" {
+		if err := writeJSON(env.Stdout, report); err != nil {
+			return err
+		}
+	} else {
+		for _, m := range report.Mismatches {
+			fmt.Fprintf(env.Stdout, "%s: %s\n", m.Table, m.Problem)
+		}
+		if report.OK() {
+			fmt.Fprintf(env.Stdout, "%s: all %d tables match\n", report.Path, report.Tables)
+		}
+	}
+	if !report.OK() {
+		return fmt.Errorf("%s: %d mismatches", report.Path, len(report.Mismatches))
+	}
+	return nil
+}
+
+func cmdValidate() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runValidate),
+		Name:     "validate",
+		Short:    "Validates a local tidyset against its server-side description",
+		Long:     "Checks that a local tidyset database has every table of the tableset, the described columns, and the described row counts. Validates the cached tidyset if no file is given. Exits non-zero if there are mismatches.",
+		ArgsName: "[--filters filters] <dataset> <version> <tableset> [file]",
+	}
+	cmd.Flags.StringVar(&filtersFlag, "filters", "", "Filters the tidyset was fetched with, in a comma-separated string.")
+	cmd.Flags.StringVar(&formatFlag, "format", "text", "Output format: text or json.")
+	return cmd
+}
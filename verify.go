@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+// newVerifier returns a verifier that records digests in, and quarantines
+// files to, the local state directory, using the --public-key flag if set.
+func newVerifier() (*tidydata.Verifier, error) {
+	manifest, err := localPath("digests.json")
+	if err != nil {
+		return nil, err
+	}
+	quarantine, err := localPath("quarantine")
+	if err != nil {
+		return nil, err
+	}
+	v := &tidydata.Verifier{ManifestPath: manifest, QuarantineDir: quarantine}
+	if publicKeyFlag != "" {
+		key, err := readBase64File(publicKeyFlag)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read public key: %v", err)
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s is not an ed25519 public key", publicKeyFlag)
+		}
+		v.PublicKey = key
+	}
+	return v, nil
+}
+
+// readBase64File returns the base64-decoded contents of a file.
+func readBase64File(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+}
+
+// setupVerification configures the global client to verify fetched data
+// according to the --verify flag and returns the expected digest given by
+// the --sha256 and --signature flags.
+func setupVerification() (tidydata.Expected, error) {
+	expected := tidydata.Expected{SHA256: strings.ToLower(sha256Flag)}
+	if !verifyFlag {
+		if expected.SHA256 != "" || signatureFlag != "" {
+			return expected, fmt.Errorf("--sha256 and --signature require --verify")
+		}
+		return expected, nil
+	}
+	v, err := newVerifier()
+	if err != nil {
+		return expected, err
+	}
+	client.SetVerifier(v)
+	if signatureFlag != "" {
+		if expected.Signature, err = readBase64File(signatureFlag); err != nil {
+			return expected, fmt.Errorf("couldn't read signature: %v", err)
+		}
+	}
+	return expected, nil
+}
+
+// addVerifyFlags adds the digest verification flags to a fetching command.
+func addVerifyFlags(cmd *cmdline.Command) {
+	cmd.Flags.BoolVar(&verifyFlag, "verify", true, "Verify the digest of the fetched data against the digest recorded when it was first fetched on this machine. This detects later changes to the local cache only; use --sha256 or --signature to check the data against its publisher's digest.")
+	cmd.Flags.StringVar(&sha256Flag, "sha256", "", "Hex-encoded SHA-256 digest the fetched data must match. It takes precedence over, and once matched replaces, the digest recorded when the data was first fetched.")
+	cmd.Flags.StringVar(&signatureFlag, "signature", "", "File containing a base64 ed25519 signature of the SHA-256 digest of the fetched data.")
+	cmd.Flags.StringVar(&publicKeyFlag, "public-key", "", "File containing the base64 ed25519 public key that verifies --signature.")
+}
+
+func runVerify(ctx *context.T, env *cmdline.Env, args []string) error {
+	v, err := newVerifier()
+	if err != nil {
+		return err
+	}
+	results, err := v.VerifyCache(args...)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Status == "mismatch" || r.Status == "error" {
+			failed++
+		}
+	}
+	if formatFlag == "json" {
+		if err := writeJSON(env.Stdout, results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			fmt.Fprintf(env.Stdout, "%-8s %s %s %s %s\n", r.Status, r.Entry.Dataset, r.Entry.Version, r.Entry.Tableset, r.Entry.Path)
+			if r.Error != "" {
+				fmt.Fprintf(env.Stdout, "         %s\n", r.Error)
+			}
+		}
+		fmt.Fprintf(env.Stdout, "%d cache entries checked, %d failed\n", len(results), failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d cache entries failed verification", failed)
+	}
+	return nil
+}
+
+func cmdVerify() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runVerify),
+		Name:     "verify",
+		Short:    "Verifies the digests of cached tidysets and preprocessed data",
+		Long:     "Recomputes the digests of cached tidysets and preprocessed data, all of them or those at the given paths, and quarantines files that no longer match the digest recorded when they were first fetched on this machine. The tidy server publishes no digests, so this detects changes to the local cache, not data that was wrong when fetched.",
+		ArgsName: "[path ...]",
+	}
+	cmd.Flags.StringVar(&formatFlag, "format", "text", "Output format: text or json.")
+	return cmd
+}
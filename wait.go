@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	vdl "grail.com/tidy/vanadium/vdl/dataset"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runWait(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) != 2 {
+		return errors.New("need exactly 2 arguments: <dataset> <version>")
+	}
+	dataset, version := args[0], args[1]
+	target, err := vdl.StateFromString(stateFlag)
+	if err != nil {
+		return fmt.Errorf("couldn't parse state %v: %v", stateFlag, err)
+	}
+	if timeoutFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeoutFlag)
+		defer cancel()
+	}
+	start := time.Now()
+	last := "unknown"
+	state, err := client.WaitForState(ctx, tidydata.WaitRequest{
+		Dataset:  dataset,
+		Version:  version,
+		State:    target,
+		Interval: waitIntervalFlag,
+		Progress: func(state string, err error) {
+			if err != nil {
+				fmt.Fprintf(env.Stderr, "poll failed: %v\n", err)
+				return
+			}
+			last = state
+			fmt.Fprintf(env.Stderr, "%s %s %s: %s\n", time.Now().Format(time.RFC3339), dataset, version, state)
+		},
+	})
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("timed out after %v waiting for %s %s to reach state %s (last seen: %s)", timeoutFlag, dataset, version, target, last)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Stdout, "%s %s reached state %s after %v\n", dataset, version, state, time.Since(start).Round(time.Second))
+	return nil
+}
+
+func cmdWait() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runWait),
+		Name:     "wait",
+		Short:    "Waits for a version to reach a publish state",
+		Long:     "Blocks until a version reaches at least the given publish state. Exits non-zero if the version fails or the timeout expires.",
+		ArgsName: "[--state state] [--timeout duration] [--interval duration] <dataset> <version>",
+	}
+	cmd.Flags.StringVar(&stateFlag, "state", "published", "minimum publish state to wait for")
+	cmd.Flags.DurationVar(&timeoutFlag, "timeout", time.Hour, "How long to wait before giving up; 0 to wait forever.")
+	cmd.Flags.DurationVar(&waitIntervalFlag, "interval", 30*time.Second, "How often to poll the server.")
+	return cmd
+}
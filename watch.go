@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"grail.com/cmd/tidydata-client/tidydata"
+	"v.io/v23/context"
+	"v.io/x/lib/cmdline"
+	"v.io/x/ref/lib/v23cmd"
+)
+
+func runWatch(ctx *context.T, env *cmdline.Env, args []string) error {
+	client = tidydata.NewClient(ctx, addressFlag)
+	if len(args) != 1 {
+		return errors.New("need exactly 1 argument: <dataset>")
+	}
+	hooks := []func(tidydata.Event) error{tidydata.PrintHook(env.Stdout)}
+	if execFlag != "" {
+		hooks = append(hooks, tidydata.ExecHook(execFlag, env.Stdout, env.Stderr))
+	}
+	if webhookFlag != "" {
+		hooks = append(hooks, tidydata.WebhookHook(ctx, webhookFlag))
+	}
+	fmt.Fprintf(env.Stderr, "watching %s every %v\n", args[0], watchIntervalFlag)
+	return client.Watch(ctx, tidydata.WatchRequest{
+		Dataset:  args[0],
+		Alias:    aliasFlag,
+		Interval: watchIntervalFlag,
+		Log:      env.Stderr,
+	}, hooks...)
+}
+
+func cmdWatch() *cmdline.Command {
+	cmd := &cmdline.Command{
+		Runner:   v23cmd.RunnerFunc(runWatch),
+		Name:     "watch",
+		Short:    "Watches a dataset for new versions, state changes and alias moves",
+		Long:     "Polls a dataset for new versions, publish state transitions and alias changes, printing each as a JSON event and passing it to an optional command or webhook.",
+		ArgsName: "[--alias alias] [--interval duration] [--exec command] [--webhook url] <dataset>",
+	}
+	cmd.Flags.StringVar(&aliasFlag, "alias", "", "Only report changes to this alias and the version it points to.")
+	cmd.Flags.DurationVar(&watchIntervalFlag, "interval", 5*time.Minute, "How often to poll the server.")
+	cmd.Flags.StringVar(&execFlag, "exec", "", "Shell command to run for each event; the JSON event is passed on standard input.")
+	cmd.Flags.StringVar(&webhookFlag, "webhook", "", "URL to POST each JSON event to.")
+	return cmd
+}